@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("import", "--file PATH [--format csv|jsonl] [--emails none|verification|welcome] [--dry-run] [--batch-size N]")
+	file := fs.String("file", "", "file to import, or - for stdin")
+	format := fs.String("format", "", "csv or jsonl (guessed from the file extension when omitted)")
+	emails := fs.String("emails", dto.ImportEmailNone, "email to send to imported users: none, verification or welcome")
+	dryRun := fs.Bool("dry-run", false, "validate the file without writing anything")
+	batchSize := fs.Int("batch-size", 0, "rows per insert batch (default 1000)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "file"); err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = formatFromPath(*file)
+	}
+	query := &dto.AdminImportUsersQuery{Format: *format, Emails: *emails, DryRun: *dryRun, BatchSize: *batchSize}
+	if err := binding.Validator.ValidateStruct(query); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	report, err := a.adminService.ImportUsers(ctx, a.actor(), bufio.NewReader(r), service.ImportOptions{
+		Format:    query.Format,
+		Emails:    query.Emails,
+		DryRun:    query.DryRun,
+		BatchSize: query.BatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(report, func(w io.Writer) {
+		if report.DryRun {
+			fmt.Fprintln(w, "Dry run, nothing was written.")
+		}
+		fmt.Fprintf(w, "Total:\t%d\n", report.Total)
+		fmt.Fprintf(w, "Imported:\t%d\n", report.Imported)
+		fmt.Fprintf(w, "Failed:\t%d\n", report.Failed)
+		if query.Emails != "" && query.Emails != dto.ImportEmailNone {
+			fmt.Fprintf(w, "Emails sent:\t%d\n", report.EmailsSent)
+			fmt.Fprintf(w, "Emails failed:\t%d\n", report.EmailsFailed)
+		}
+		if len(report.Errors) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "LINE\tUSERNAME\tEMAIL\tERROR")
+			for _, e := range report.Errors {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.Line, e.Username, e.Email, e.Error)
+			}
+		}
+	})
+}
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("export", "[--format csv|jsonl] [--output PATH] [--status active|deleted|suspended] [--with-password-hashes]")
+	format := fs.String("format", "", "csv or jsonl (guessed from --output when omitted, csv otherwise)")
+	output := fs.String("output", "-", "file to write, or - for stdout")
+	status := fs.String("status", "", "only export users in this state")
+	withHashes := fs.Bool("with-password-hashes", false, "include bcrypt password hashes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = formatFromPath(*output)
+	}
+	query := &dto.AdminExportUsersQuery{Format: *format, Status: *status, IncludePasswordHash: *withHashes}
+	if err := binding.Validator.ValidateStruct(query); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := a.adminService.ExportUsers(ctx, a.actor(), bw, query); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// formatFromPath picks jsonl for .jsonl and .ndjson files and csv otherwise.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return dto.BulkFormatJSONL
+	default:
+		return dto.BulkFormatCSV
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/zhanserikAmangeldi/user-service/internal/tlsconfig"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func runDevCerts(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("dev-certs", "[--out DIR] [--hosts HOSTS] [--clients NAMES] [--trust-domain DOMAIN] [--valid-for DURATION]")
+	outDir := fs.String("out", "certs", "directory to write the certificates to")
+	hosts := fs.String("hosts", "localhost,127.0.0.1,user-service", "comma-separated DNS names and IPs of the server certificate")
+	clients := fs.String("clients", "", "comma-separated service names to issue client certificates for")
+	trustDomain := fs.String("trust-domain", "chat.local", "trust domain of the spiffe:// client identities")
+	validFor := fs.Duration("valid-for", 365*24*time.Hour, "validity of the certificates")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	serverHosts := splitList(*hosts)
+	if len(serverHosts) == 0 {
+		fs.Usage()
+		return fmt.Errorf("--hosts needs at least one DNS name or IP")
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return err
+	}
+
+	ca, err := tlsconfig.NewDevCA(*validFor)
+	if err != nil {
+		return err
+	}
+	caKey, err := ca.KeyPEM()
+	if err != nil {
+		return err
+	}
+
+	files := []string{}
+	write := func(name string, data []byte, private bool) error {
+		path := filepath.Join(*outDir, name)
+		files = append(files, path)
+		return tlsconfig.WriteFile(path, data, private)
+	}
+
+	if err := write("ca.pem", ca.CertPEM(), false); err != nil {
+		return err
+	}
+	if err := write("ca-key.pem", caKey, true); err != nil {
+		return err
+	}
+
+	certPEM, keyPEM, err := ca.IssueServer(serverHosts)
+	if err != nil {
+		return err
+	}
+	if err := write("server.pem", certPEM, false); err != nil {
+		return err
+	}
+	if err := write("server-key.pem", keyPEM, true); err != nil {
+		return err
+	}
+
+	identities := map[string]string{}
+	for _, name := range splitList(*clients) {
+		identity := &url.URL{Scheme: "spiffe", Host: *trustDomain, Path: "/" + name}
+		certPEM, keyPEM, err := ca.IssueClient(name, identity)
+		if err != nil {
+			return err
+		}
+		if err := write(name+".pem", certPEM, false); err != nil {
+			return err
+		}
+		if err := write(name+"-key.pem", keyPEM, true); err != nil {
+			return err
+		}
+		identities[name] = identity.String()
+	}
+
+	result := map[string]any{
+		"files":      files,
+		"identities": identities,
+	}
+
+	return a.out.print(result, func(w io.Writer) {
+		for _, path := range files {
+			fmt.Fprintf(w, "Wrote:\t%s\n", path)
+		}
+		for name, identity := range identities {
+			fmt.Fprintf(w, "Identity of %s:\t%s\n", name, identity)
+		}
+		fmt.Fprintln(w, "Register client identities with 'admin service-clients create --tls-identity'.")
+		fmt.Fprintln(w, "These certificates are for local development only.")
+	})
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+func runRotateKeys(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("rotate-keys", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := a.keyService.Rotate(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]any{
+		"kid":          key.KID,
+		"algorithm":    key.Algorithm,
+		"created_at":   key.CreatedAt,
+		"activates_at": key.ActivatesAt,
+	}
+
+	return a.out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "New signing key:\t%s (%s)\n", key.KID, key.Algorithm)
+		fmt.Fprintf(w, "Created:\t%s\n", key.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "Activates:\t%s\n", key.ActivatesAt.Format(time.RFC3339))
+		fmt.Fprintln(w, "Running API instances publish it now and sign with it once it activates.")
+	})
+}
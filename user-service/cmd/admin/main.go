@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/zhanserikAmangeldi/user-service/internal/cache"
+	"github.com/zhanserikAmangeldi/user-service/internal/config"
+	"github.com/zhanserikAmangeldi/user-service/internal/mailer"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	summary string
+	// offline commands do not need database and Redis connections.
+	offline bool
+	run     func(ctx context.Context, app *app, args []string) error
+}
+
+var commands = map[string]command{
+	"create-user":     {summary: "Create a user account", run: runCreateUser},
+	"create-admin":    {summary: "Create an admin account", run: runCreateAdmin},
+	"set-role":        {summary: "Change the role of a user", run: runSetRole},
+	"reset-password":  {summary: "Set a new password and revoke all sessions", run: runResetPassword},
+	"verify-email":    {summary: "Mark the email of a user as verified", run: runVerifyEmail},
+	"sessions":        {summary: "List or revoke sessions of a user", run: runSessions},
+	"suspend":         {summary: "Suspend a user temporarily or permanently", run: runSuspend},
+	"unsuspend":       {summary: "Lift the active suspension of a user", run: runUnsuspend},
+	"migrate":         {summary: "Apply or roll back database migrations", offline: true, run: runMigrate},
+	"rotate-keys":     {summary: "Generate a new token signing key", run: runRotateKeys},
+	"import":          {summary: "Import users from a CSV or JSONL file", run: runImport},
+	"export":          {summary: "Export users as CSV or JSONL", run: runExport},
+	"service-clients": {summary: "Register and manage service clients", run: runServiceClients},
+	"dev-certs":       {summary: "Generate a local CA and TLS certificates for development", offline: true, run: runDevCerts},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: admin [--json] <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-17s %s\n", name, commands[name].summary)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'admin <command> --help' for the flags of a command.")
+}
+
+func main() {
+	global := flag.NewFlagSet("admin", flag.ExitOnError)
+	jsonOutput := global.Bool("json", false, "print results as JSON")
+	global.Usage = usage
+	_ = global.Parse(os.Args[1:])
+
+	args := global.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	app := &app{
+		cfg: config.LoadConfig(),
+		out: &printer{json: *jsonOutput, w: os.Stdout},
+	}
+
+	if !cmd.offline {
+		if err := app.connect(ctx); err != nil {
+			fatal(err)
+		}
+		defer app.close()
+	}
+
+	if err := cmd.run(ctx, app, args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
+}
+
+// app holds the dependencies shared by commands. It is wired the same way as
+// cmd/api.
+type app struct {
+	cfg *config.Config
+	out *printer
+
+	db    *pgxpool.Pool
+	redis *redis.Client
+
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+
+	authService  *service.AuthService
+	adminService *service.AdminService
+	keyService   *service.KeyService
+
+	serviceClientService *service.ServiceClientService
+}
+
+func (a *app) connect(ctx context.Context) error {
+	db, err := pgxpool.New(ctx, a.cfg.DatabaseURL())
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	if err := db.Ping(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("ping database: %w", err)
+	}
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: a.cfg.RedisAddr(),
+		DB:   0,
+	})
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		db.Close()
+		_ = redisClient.Close()
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+
+	a.db = db
+	a.redis = redisClient
+
+	a.userRepo = repository.NewUserRepository(db, cache.New(redisClient, "user", a.cfg.UserCacheTTL))
+	a.sessionRepo = repository.NewSessionRepository(db, cache.New(redisClient, "session", a.cfg.SessionCacheTTL))
+	emailRepo := repository.NewEmailVerificationRepository(db)
+	auditRepo := repository.NewAuditLogRepository(db)
+	suspensionRepo := repository.NewSuspensionRepository(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+
+	smtp := &mailer.SMTPMailer{
+		Host:    a.cfg.SMTPHost,
+		Port:    a.cfg.SMTPPort,
+		User:    a.cfg.SMTPUser,
+		Pass:    a.cfg.SMTPPassword,
+		From:    a.cfg.SMTPFrom,
+		BaseURL: a.cfg.AppBaseURL,
+		Render:  mailer.NewTemplateRender(a.cfg.MailTemplatesDir),
+	}
+
+	tokenManager := jwt.NewTokenManager(a.cfg.JWTSecret)
+	a.keyService = service.NewKeyService(signingKeyRepo, tokenManager, a.cfg.SessionAbsoluteLifetime)
+	revocations := revocation.NewStore(redisClient, a.cfg.SessionAbsoluteLifetime, nil)
+	sessionPolicy := service.SessionPolicy{
+		AbsoluteLifetime: a.cfg.SessionAbsoluteLifetime,
+		IdleTimeout:      a.cfg.SessionIdleTimeout,
+		Limit: repository.SessionLimit{
+			Max:      a.cfg.SessionMaxPerUser,
+			Eviction: repository.EvictionPolicy(a.cfg.SessionEvictionPolicy),
+		},
+	}
+	a.authService = service.NewAuthService(a.userRepo, a.sessionRepo, tokenManager, emailRepo, suspensionRepo, smtp, redisClient, revocations, nil, nil, sessionPolicy)
+	a.adminService = service.NewAdminService(a.userRepo, a.sessionRepo, auditRepo, suspensionRepo, a.authService)
+	a.serviceClientService = service.NewServiceClientService(repository.NewServiceClientRepository(db, cache.New(redisClient, "service_client", a.cfg.ServiceClientCacheTTL)), tokenManager, a.cfg.ServiceTokenTTL)
+
+	return nil
+}
+
+func (a *app) close() {
+	if a.redis != nil {
+		_ = a.redis.Close()
+	}
+	if a.db != nil {
+		a.db.Close()
+	}
+}
+
+// actor attributes CLI actions in the audit log to the operating system user.
+func (a *app) actor() service.Actor {
+	osUser := os.Getenv("USER")
+	if osUser == "" {
+		osUser = "unknown"
+	}
+	userAgent := "admin-cli/" + osUser
+	return service.Actor{UserAgent: &userAgent}
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors instead of exiting.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: admin %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func requireFlags(fs *flag.FlagSet, names ...string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var missing []string
+	for _, name := range names {
+		if !set[name] {
+			missing = append(missing, "--"+name)
+		}
+	}
+
+	if len(missing) > 0 {
+		fs.Usage()
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
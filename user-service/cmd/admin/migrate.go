@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("migrate", "[--dir DIR] up|down [N]|version|force VERSION")
+	dir := fs.String("dir", "migrations", "directory containing the migration files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		fs.Usage()
+		return errors.New("missing migrate subcommand")
+	}
+
+	// golang-migrate selects its driver by URL scheme.
+	dbURL := strings.Replace(a.cfg.DatabaseURL(), "postgres://", "pgx5://", 1)
+	m, err := migrate.New("file://"+*dir, dbURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	go func() {
+		<-ctx.Done()
+		m.GracefulStop <- true
+	}()
+
+	switch rest[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		steps := 1
+		if len(rest) > 1 {
+			steps, err = strconv.Atoi(rest[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", rest[1])
+			}
+		}
+		err = m.Steps(-steps)
+	case "force":
+		if len(rest) < 2 {
+			return errors.New("usage: admin migrate force VERSION")
+		}
+		version, convErr := strconv.Atoi(rest[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q", rest[1])
+		}
+		err = m.Force(version)
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q", rest[0])
+	}
+
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return err
+	}
+
+	result := map[string]any{"version": version, "dirty": dirty}
+	return a.out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Version:\t%d\n", version)
+		fmt.Fprintf(w, "Dirty:\t%t\n", dirty)
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// printer writes either machine-readable JSON or a human-readable table.
+type printer struct {
+	json bool
+	w    io.Writer
+}
+
+// print encodes v as JSON in --json mode, and calls text otherwise.
+func (p *printer) print(v any, text func(w io.Writer)) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+// message prints a confirmation such as "password reset".
+func (p *printer) message(msg string, fields map[string]any) error {
+	out := map[string]any{"message": msg}
+	for k, v := range fields {
+		out[k] = v
+	}
+
+	return p.print(out, func(w io.Writer) {
+		fmt.Fprintln(w, msg)
+	})
+}
+
+func valueOr(s *string, fallback string) string {
+	if s == nil || *s == "" {
+		return fallback
+	}
+	return *s
+}
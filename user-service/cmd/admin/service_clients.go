@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"io"
+	"strings"
+	"time"
+)
+
+func runServiceClients(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: admin service-clients create|list|disable|rotate-secret")
+	}
+
+	switch args[0] {
+	case "create":
+		return createServiceClient(ctx, a, args[1:])
+	case "list":
+		return listServiceClients(ctx, a, args[1:])
+	case "disable":
+		return disableServiceClient(ctx, a, args[1:])
+	case "rotate-secret":
+		return rotateServiceClientSecret(ctx, a, args[1:])
+	default:
+		return fmt.Errorf("unknown service-clients subcommand %q", args[0])
+	}
+}
+
+func createServiceClient(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("service-clients create", "--id ID --name NAME --scopes SCOPES [--tls-identity ID] [--no-secret]")
+	clientID := fs.String("id", "", "client ID the service authenticates with")
+	name := fs.String("name", "", "human readable name")
+	scopes := fs.String("scopes", "", "comma-separated scopes: "+strings.Join(models.ServiceScopes, ", "))
+	tlsIdentity := fs.String("tls-identity", "", "URI SAN or common name of the client certificate")
+	noSecret := fs.Bool("no-secret", false, "authenticate with the client certificate only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id", "name", "scopes"); err != nil {
+		return err
+	}
+
+	client, secret, err := a.serviceClientService.Register(ctx, *clientID, *name, splitList(*scopes), *tlsIdentity, !*noSecret)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]any{
+		"client_id": client.ClientID,
+		"scopes":    client.Scopes,
+	}
+	if secret != "" {
+		result["client_secret"] = secret
+	}
+	if client.TLSIdentity != nil {
+		result["tls_identity"] = *client.TLSIdentity
+	}
+
+	return a.out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Client ID:\t%s\n", client.ClientID)
+		fmt.Fprintf(w, "Scopes:\t%s\n", strings.Join(client.Scopes, " "))
+		if client.TLSIdentity != nil {
+			fmt.Fprintf(w, "TLS identity:\t%s\n", *client.TLSIdentity)
+		}
+		if secret != "" {
+			fmt.Fprintf(w, "Client secret:\t%s\n", secret)
+			fmt.Fprintln(w, "Store the secret now, it is not shown again.")
+		}
+	})
+}
+
+func listServiceClients(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("service-clients list", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	clients, err := a.serviceClientService.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(clients, func(w io.Writer) {
+		fmt.Fprintln(w, "CLIENT ID\tNAME\tSCOPES\tTLS IDENTITY\tSECRET\tCREATED\tSTATUS")
+		for _, client := range clients {
+			secret, status := "no", "active"
+			if client.SecretHash != nil {
+				secret = "yes"
+			}
+			if client.IsDisabled() {
+				status = "disabled"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				client.ClientID,
+				client.Name,
+				strings.Join(client.Scopes, " "),
+				valueOr(client.TLSIdentity, "-"),
+				secret,
+				client.CreatedAt.Format(time.RFC3339),
+				status,
+			)
+		}
+	})
+}
+
+func disableServiceClient(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("service-clients disable", "--id ID")
+	clientID := fs.String("id", "", "client ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id"); err != nil {
+		return err
+	}
+
+	if err := a.serviceClientService.Disable(ctx, *clientID); err != nil {
+		return err
+	}
+
+	return a.out.message("service client disabled; tokens already issued stay valid until they expire",
+		map[string]any{"client_id": *clientID})
+}
+
+func rotateServiceClientSecret(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("service-clients rotate-secret", "--id ID")
+	clientID := fs.String("id", "", "client ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "id"); err != nil {
+		return err
+	}
+
+	secret, err := a.serviceClientService.RotateSecret(ctx, *clientID)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]any{"client_id": *clientID, "client_secret": secret}
+
+	return a.out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Client ID:\t%s\n", *clientID)
+		fmt.Fprintf(w, "Client secret:\t%s\n", secret)
+		fmt.Fprintln(w, "Store the secret now, it is not shown again. The old secret no longer works.")
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"io"
+	"time"
+)
+
+func runSessions(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: admin sessions list|revoke --user USER")
+	}
+
+	switch args[0] {
+	case "list":
+		return listSessions(ctx, a, args[1:])
+	case "revoke":
+		return revokeSessions(ctx, a, args[1:])
+	default:
+		return fmt.Errorf("unknown sessions subcommand %q", args[0])
+	}
+}
+
+func listSessions(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("sessions list", "--user USER")
+	userRef := fs.String("user", "", "user ID, email or username")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user"); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	details, err := a.adminService.GetUserDetails(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	resp := &models.SessionListResponse{
+		Sessions: details.Sessions,
+		Total:    len(details.Sessions),
+	}
+
+	return a.out.print(resp, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tCREATED\tEXPIRES\tIP\tUSER AGENT")
+		for _, sess := range resp.Sessions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+				sess.ID,
+				sess.CreatedAt.Format(time.RFC3339),
+				sess.ExpiresAt.Format(time.RFC3339),
+				valueOr(sess.IPAddress, "-"),
+				valueOr(sess.UserAgent, "-"),
+			)
+		}
+	})
+}
+
+func revokeSessions(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("sessions revoke", "--user USER")
+	userRef := fs.String("user", "", "user ID, email or username")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user"); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	if err := a.adminService.RevokeSessions(ctx, a.actor(), user.ID); err != nil {
+		return err
+	}
+
+	return a.out.message("all sessions revoked", map[string]any{"user_id": user.ID})
+}
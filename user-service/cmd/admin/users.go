@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resolveUser accepts a numeric ID, an email address or a username.
+func (a *app) resolveUser(ctx context.Context, ref string) (*models.User, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return a.userRepo.GetByIDUnscoped(ctx, id)
+	}
+	if strings.Contains(ref, "@") {
+		return a.userRepo.GetByEmail(ctx, ref)
+	}
+	return a.userRepo.GetByUsername(ctx, ref)
+}
+
+// readPassword returns the --password flag value, or reads the first line of
+// stdin so the password does not end up in shell history.
+func readPassword(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	fmt.Fprintln(os.Stderr)
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	return password, nil
+}
+
+func printUser(w io.Writer, user *models.User) {
+	fmt.Fprintf(w, "ID:\t%d\n", user.ID)
+	fmt.Fprintf(w, "Username:\t%s\n", user.Username)
+	fmt.Fprintf(w, "Email:\t%s\n", user.Email)
+	fmt.Fprintf(w, "Display name:\t%s\n", valueOr(user.DisplayName, "-"))
+	fmt.Fprintf(w, "Role:\t%s\n", user.Role)
+	fmt.Fprintf(w, "Verified:\t%t\n", user.IsVerified)
+	fmt.Fprintf(w, "Created:\t%s\n", user.CreatedAt.Format(time.RFC3339))
+	if user.DeletedAt != nil {
+		fmt.Fprintf(w, "Deleted:\t%s\n", user.DeletedAt.Format(time.RFC3339))
+	}
+}
+
+func runCreateUser(ctx context.Context, a *app, args []string) error {
+	return createUser(ctx, a, "create-user", models.RoleUser, args)
+}
+
+func runCreateAdmin(ctx context.Context, a *app, args []string) error {
+	return createUser(ctx, a, "create-admin", models.RoleAdmin, args)
+}
+
+func createUser(ctx context.Context, a *app, name, role string, args []string) error {
+	fs := newFlagSet(name, "--username NAME --email EMAIL [--password PASS] [--display-name NAME] [--verified]")
+	username := fs.String("username", "", "username")
+	email := fs.String("email", "", "email address")
+	password := fs.String("password", "", "password (read from stdin when omitted)")
+	displayName := fs.String("display-name", "", "display name")
+	verified := fs.Bool("verified", false, "mark the email as verified")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "username", "email"); err != nil {
+		return err
+	}
+
+	pass, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	req := &dto.AdminCreateUserRequest{
+		Username:    *username,
+		Email:       *email,
+		Password:    pass,
+		DisplayName: *displayName,
+		Role:        role,
+		Verified:    *verified,
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return err
+	}
+
+	user, err := a.adminService.CreateUser(ctx, a.actor(), req)
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(user, func(w io.Writer) {
+		printUser(w, user)
+	})
+}
+
+func runSetRole(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("set-role", "--user USER --role user|admin")
+	userRef := fs.String("user", "", "user ID, email or username")
+	role := fs.String("role", "", "new role: user or admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user", "role"); err != nil {
+		return err
+	}
+	if err := binding.Validator.ValidateStruct(&dto.AdminSetRoleRequest{Role: *role}); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	if err := a.adminService.SetRole(ctx, a.actor(), user.ID, *role); err != nil {
+		return err
+	}
+
+	return a.out.message("role updated", map[string]any{"user_id": user.ID, "role": *role})
+}
+
+func runResetPassword(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("reset-password", "--user USER [--password PASS]")
+	userRef := fs.String("user", "", "user ID, email or username")
+	password := fs.String("password", "", "new password (read from stdin when omitted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user"); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	pass, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	if err := binding.Validator.ValidateStruct(&dto.AdminResetPasswordRequest{Password: pass}); err != nil {
+		return err
+	}
+
+	if err := a.adminService.ResetPassword(ctx, a.actor(), user.ID, pass); err != nil {
+		return err
+	}
+
+	return a.out.message("password reset, all sessions revoked", map[string]any{"user_id": user.ID})
+}
+
+func runVerifyEmail(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("verify-email", "--user USER")
+	userRef := fs.String("user", "", "user ID, email or username")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user"); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	if err := a.adminService.VerifyEmail(ctx, a.actor(), user.ID); err != nil {
+		return err
+	}
+
+	return a.out.message("email verified", map[string]any{"user_id": user.ID})
+}
+
+func runSuspend(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("suspend", "--user USER --reason TEXT [--for DURATION | --until RFC3339]")
+	userRef := fs.String("user", "", "user ID, email or username")
+	reason := fs.String("reason", "", "reason shown to the user")
+	duration := fs.Duration("for", 0, "suspension length, e.g. 72h (permanent when neither --for nor --until is set)")
+	until := fs.String("until", "", "end of the suspension in RFC 3339")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user", "reason"); err != nil {
+		return err
+	}
+
+	req := &dto.AdminSuspendUserRequest{Reason: *reason}
+	switch {
+	case *duration > 0 && *until != "":
+		return errors.New("--for and --until are mutually exclusive")
+	case *duration > 0:
+		endsAt := time.Now().Add(*duration)
+		req.EndsAt = &endsAt
+	case *until != "":
+		endsAt, err := time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+		req.EndsAt = &endsAt
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	suspension, err := a.adminService.SuspendUser(ctx, a.actor(), user.ID, req)
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(suspension, func(w io.Writer) {
+		fmt.Fprintf(w, "Suspended:\t%s (ID %d)\n", user.Username, user.ID)
+		fmt.Fprintf(w, "Reason:\t%s\n", suspension.Reason)
+		if suspension.EndsAt != nil {
+			fmt.Fprintf(w, "Until:\t%s\n", suspension.EndsAt.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(w, "Until:\tpermanent\n")
+		}
+	})
+}
+
+func runUnsuspend(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet("unsuspend", "--user USER")
+	userRef := fs.String("user", "", "user ID, email or username")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlags(fs, "user"); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *userRef)
+	if err != nil {
+		return err
+	}
+
+	if err := a.adminService.LiftSuspension(ctx, a.actor(), user.ID); err != nil {
+		return err
+	}
+
+	return a.out.message("suspension lifted", map[string]any{"user_id": user.ID})
+}
@@ -2,29 +2,38 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+	"github.com/zhanserikAmangeldi/user-service/internal/cache"
 	"github.com/zhanserikAmangeldi/user-service/internal/config"
+	"github.com/zhanserikAmangeldi/user-service/internal/geoip"
+	"github.com/zhanserikAmangeldi/user-service/internal/grpcserver"
 	"github.com/zhanserikAmangeldi/user-service/internal/handler"
 	"github.com/zhanserikAmangeldi/user-service/internal/mailer"
 	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/presence"
 	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/scheduler"
 	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"github.com/zhanserikAmangeldi/user-service/internal/tlsconfig"
+	"github.com/zhanserikAmangeldi/user-service/pkg/dpop"
 	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
 	"log"
+	"net"
 	"net/http"
+	"time"
 )
 
 func main() {
 	cfg := config.LoadConfig()
 	ctx := context.Background()
 
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
-
-	dbPool, err := pgxpool.New(ctx, dbURL)
+	dbPool, err := pgxpool.New(ctx, cfg.DatabaseURL())
 	if err != nil {
 		log.Fatalf("Unable to connect to database: %v", err)
 	}
@@ -36,7 +45,7 @@ func main() {
 	log.Println("Connected to PostgreSQL")
 
 	redisClient := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
+		Addr: cfg.RedisAddr(),
 		DB:   0,
 	})
 	defer redisClient.Close()
@@ -46,28 +55,132 @@ func main() {
 	}
 	log.Println("Connected to Redis")
 
-	render := mailer.NewTemplateRender("internal/mailer/templates")
+	render := mailer.NewTemplateRender(cfg.MailTemplatesDir)
 
 	smtp := mailer.SMTPMailer{
-		Host:    "smtp.gmail.com",
-		Port:    587,
-		User:    "amangeldi.janserik2017@gmail.com",
-		Pass:    "xsts bhls apvn ucol",
-		From:    "Your new best chat application :))) <noreply@chat.com>",
-		BaseURL: "localhost:8081",
+		Host:    cfg.SMTPHost,
+		Port:    cfg.SMTPPort,
+		User:    cfg.SMTPUser,
+		Pass:    cfg.SMTPPassword,
+		From:    cfg.SMTPFrom,
+		BaseURL: cfg.AppBaseURL,
 		Render:  render,
 	}
+	if !smtp.Configured() {
+		log.Println("[WARN] SMTP_USER or SMTP_PASSWORD is not set, emails will not be sent")
+	}
 
-	userRepo := repository.NewUserRepository(dbPool)
-	sessionRepo := repository.NewSessionRepository(dbPool)
+	userRepo := repository.NewUserRepository(dbPool, cache.New(redisClient, "user", cfg.UserCacheTTL))
+	sessionRepo := repository.NewSessionRepository(dbPool, cache.New(redisClient, "session", cfg.SessionCacheTTL))
 	emailRepo := repository.NewEmailVerificationRepository(dbPool)
+	auditRepo := repository.NewAuditLogRepository(dbPool)
+	suspensionRepo := repository.NewSuspensionRepository(dbPool)
+	signingKeyRepo := repository.NewSigningKeyRepository(dbPool)
+	deviceAuthRepo := repository.NewDeviceAuthorizationRepository(dbPool)
+	serviceClientRepo := repository.NewServiceClientRepository(dbPool, cache.New(redisClient, "service_client", cfg.ServiceClientCacheTTL))
 
 	tokenManager := jwt.NewTokenManager(cfg.JWTSecret)
-	authService := service.NewAuthService(userRepo, sessionRepo, tokenManager, emailRepo, &smtp, redisClient)
+	keyService := service.NewKeyService(signingKeyRepo, tokenManager, cfg.SessionAbsoluteLifetime)
+	if err := keyService.Reload(ctx); err != nil {
+		log.Fatalf("Unable to load signing keys: %v", err)
+	}
+	go keyService.RunReloader(ctx, service.KeyReloadInterval)
+
+	geoLocator, err := geoip.NewLocator(cfg.GeoIPDatabasePath)
+	if cfg.GeoIPDatabasePath == "" {
+		log.Println("[INFO] GEOIP_DB_PATH is not set, GeoIP lookups are disabled")
+	} else if err != nil {
+		log.Printf("[WARN] GeoIP database not loaded, sessions will have no location: %v", err)
+	}
+	defer geoLocator.Close()
+	go geoLocator.RunReloader(ctx, cfg.GeoIPReloadInterval)
+
+	clientAuth, err := tlsconfig.ParseClientAuth(cfg.TLSClientAuth)
+	if err != nil {
+		log.Fatalf("Invalid TLS_CLIENT_AUTH: %v, expected none, request or require", err)
+	}
+	tlsReloader, err := tlsconfig.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile, clientAuth)
+	if err != nil {
+		log.Fatalf("Unable to load TLS certificate: %v", err)
+	}
+	go tlsReloader.RunReloader(ctx, cfg.TLSReloadInterval)
 
-	authHandler := handler.NewAuthHandler(authService)
-	userHandler := handler.NewUserHandler(userRepo)
+	sessionPolicy := service.SessionPolicy{
+		AbsoluteLifetime: cfg.SessionAbsoluteLifetime,
+		IdleTimeout:      cfg.SessionIdleTimeout,
+		Limit: repository.SessionLimit{
+			Max:      cfg.SessionMaxPerUser,
+			Eviction: repository.EvictionPolicy(cfg.SessionEvictionPolicy),
+		},
+	}
+	if !sessionPolicy.Limit.Eviction.Valid() {
+		log.Fatalf("Invalid SESSION_EVICTION_POLICY %q, expected reject, evict_oldest or evict_lru", cfg.SessionEvictionPolicy)
+	}
+	revocationChecker := revocation.NewChecker(redisClient, cfg.RevocationCacheTTL)
+	revocationStore := revocation.NewStore(redisClient, cfg.SessionAbsoluteLifetime, revocationChecker)
+
+	presenceHub := presence.NewHub(redisClient)
+	go presenceHub.Run(ctx)
+	presenceTracker := presence.NewTracker(redisClient, cfg.PresenceHeartbeatTTL, cfg.PresenceAwayAfter)
+	presenceService := service.NewPresenceService(userRepo, presenceTracker, presenceHub)
+	go presenceService.RunSweeper(ctx, cfg.PresenceSweepInterval)
+
+	authService := service.NewAuthService(userRepo, sessionRepo, tokenManager, emailRepo, suspensionRepo, &smtp, redisClient, revocationStore, geoLocator, presenceService, sessionPolicy)
+	adminService := service.NewAdminService(userRepo, sessionRepo, auditRepo, suspensionRepo, authService)
+	deviceAuthService := service.NewDeviceAuthService(deviceAuthRepo, authService, cfg.DeviceVerificationURI,
+		cfg.DeviceCodeLifetime, cfg.DevicePollInterval)
+	qrLoginService := service.NewQRLoginService(redisClient, authService, cfg.QRLoginTTL, cfg.QRLoginPollTimeout)
+	wsTicketService := service.NewWSTicketService(redisClient, revocationChecker, cfg.WSTicketTTL)
+	serviceClientService := service.NewServiceClientService(serviceClientRepo, tokenManager, cfg.ServiceTokenTTL)
+	tokenValidator := service.NewTokenValidator(tokenManager, revocationChecker)
+	maintenanceService := service.NewMaintenanceService(sessionRepo, emailRepo, signingKeyRepo, deviceAuthRepo, cfg.DataRetention)
+
+	jobScheduler := scheduler.New(redisClient, cfg.InstanceID)
+	for _, job := range []scheduler.Job{
+		{Name: "sessions.cleanup", Interval: cfg.CleanupInterval, Run: maintenanceService.CleanupSessions},
+		{Name: "email_verifications.cleanup", Interval: cfg.CleanupInterval, Run: maintenanceService.CleanupEmailVerifications},
+		{Name: "signing_keys.cleanup", Interval: 24 * time.Hour, Run: maintenanceService.CleanupSigningKeys},
+		{Name: "device_authorizations.cleanup", Interval: cfg.CleanupInterval, Run: maintenanceService.CleanupDeviceAuthorizations},
+	} {
+		if err := jobScheduler.Register(job); err != nil {
+			log.Fatalf("Unable to register job %s: %v", job.Name, err)
+		}
+	}
+	if cfg.SchedulerEnabled {
+		go jobScheduler.Run(ctx)
+	} else {
+		log.Println("[INFO] SCHEDULER_ENABLED=false, periodic jobs will only run when triggered")
+	}
+
+	sameSite, err := handler.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		log.Fatalf("Invalid COOKIE_SAMESITE: %v", err)
+	}
+	if sameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		log.Fatalf("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+	}
+	cookieConfig := handler.CookieConfig{
+		Enabled:       cfg.CookieAuthEnabled,
+		Domain:        cfg.CookieDomain,
+		Secure:        cfg.CookieSecure,
+		SameSite:      sameSite,
+		RefreshCookie: cfg.RefreshCookieName,
+		CSRFCookie:    cfg.CSRFCookieName,
+	}
+
+	dpopVerifier := dpop.NewVerifier(redisClient, cfg.DPoPProofMaxAge)
+
+	authHandler := handler.NewAuthHandler(authService, cookieConfig, dpopVerifier)
+	deviceAuthHandler := handler.NewDeviceAuthHandler(deviceAuthService, dpopVerifier)
+	qrLoginHandler := handler.NewQRLoginHandler(qrLoginService, authHandler)
+	wsTicketHandler := handler.NewWSTicketHandler(wsTicketService)
+	serviceClientHandler := handler.NewServiceClientHandler(serviceClientService)
+	tokenHandler := handler.NewTokenHandler(tokenManager, tokenValidator)
+	userHandler := handler.NewUserHandler(userRepo, presenceService)
+	presenceHandler := handler.NewPresenceHandler(presenceService)
 	emailVerificationHandler := handler.NewEmailVerificationHandler(authService)
+	adminHandler := handler.NewAdminHandler(adminService)
+	jobHandler := handler.NewJobHandler(jobScheduler, adminService)
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
@@ -75,7 +188,7 @@ func main() {
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, DPoP, X-Auth-Mode, X-CSRF-Token")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -94,7 +207,15 @@ func main() {
 		})
 	})
 
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	router.GET("/verify-email", emailVerificationHandler.VerifyEmail)
+	router.GET("/.well-known/jwks.json", tokenHandler.JWKS)
+
+	csrf := gin.HandlerFunc(func(c *gin.Context) { c.Next() })
+	if cfg.CookieAuthEnabled {
+		csrf = middleware.CSRF(cfg.RefreshCookieName, cfg.CSRFCookieName)
+	}
 
 	v1 := router.Group("/api/v1")
 	{
@@ -102,18 +223,44 @@ func main() {
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
-			auth.POST("/refresh", authHandler.RefreshToken)
-			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/refresh", csrf, authHandler.RefreshToken)
+			auth.POST("/logout", csrf, authHandler.Logout)
+			auth.POST("/device/code", deviceAuthHandler.RequestCode)
+			auth.POST("/device/token", deviceAuthHandler.Token)
+			auth.POST("/qr", qrLoginHandler.CreateTicket)
+			auth.POST("/qr/poll", qrLoginHandler.Poll)
+			auth.POST("/token", serviceClientHandler.Token)
+			auth.POST("/introspect",
+				middleware.ServiceAuth(tokenManager, serviceClientRepo, models.ScopeTokensValidate), tokenHandler.Introspect)
+		}
+
+		// Internal routes are called by other services with service tokens.
+		internal := v1.Group("/internal")
+		{
+			internal.POST("/ws-tickets/validate",
+				middleware.ServiceAuth(tokenManager, serviceClientRepo, models.ScopeWSTicketsValidate), wsTicketHandler.Validate)
+			internal.GET("/users/:id",
+				middleware.ServiceAuth(tokenManager, serviceClientRepo, models.ScopeUsersRead), userHandler.GetUserByID)
 		}
 	}
 
 	protected := v1.Group("")
-	protected.Use(middleware.AuthMiddleware(tokenManager, redisClient))
+	protected.Use(middleware.AuthMiddleware(tokenManager, revocationChecker, dpopVerifier))
+	protected.Use(middleware.AuditImpersonation(auditRepo))
 	{
 		auth := protected.Group("/auth")
 		{
-			auth.POST("/logout-all", authHandler.LogoutAll)
+			auth.POST("/logout-all", middleware.DenyImpersonation(), authHandler.LogoutAll)
+			auth.POST("/logout-others", middleware.DenyImpersonation(), authHandler.LogoutOthers)
 			auth.GET("/sessions", authHandler.GetActiveSessions)
+			auth.DELETE("/sessions/:id", middleware.DenyImpersonation(), authHandler.RevokeSession)
+			auth.GET("/device", deviceAuthHandler.GetPending)
+			auth.POST("/device/approve", middleware.DenyImpersonation(), deviceAuthHandler.Approve)
+			auth.POST("/device/deny", middleware.DenyImpersonation(), deviceAuthHandler.Deny)
+			auth.GET("/qr", qrLoginHandler.GetTicket)
+			auth.POST("/qr/approve", middleware.DenyImpersonation(), qrLoginHandler.Approve)
+			auth.POST("/qr/deny", middleware.DenyImpersonation(), qrLoginHandler.Deny)
+			auth.POST("/ws-ticket", middleware.DenyImpersonation(), wsTicketHandler.Issue)
 		}
 
 		users := protected.Group("/users")
@@ -122,15 +269,75 @@ func main() {
 			users.PUT("/me", userHandler.UpdateMe)
 			users.GET("/:id", userHandler.GetUserByID)
 		}
+
+		presenceRoutes := protected.Group("/presence")
+		presenceRoutes.Use(middleware.DenyImpersonation())
+		{
+			presenceRoutes.POST("/heartbeat", presenceHandler.Heartbeat)
+			presenceRoutes.POST("/disconnect", presenceHandler.Disconnect)
+		}
+
+		admin := protected.Group("/admin")
+		admin.Use(middleware.DenyImpersonation(), middleware.RequireAdmin(userRepo))
+		{
+			admin.GET("/users", adminHandler.ListUsers)
+			admin.POST("/users", adminHandler.CreateUser)
+			admin.POST("/users/import", adminHandler.ImportUsers)
+			admin.GET("/users/export", adminHandler.ExportUsers)
+			admin.GET("/users/:id", adminHandler.GetUser)
+			admin.PUT("/users/:id/role", adminHandler.SetRole)
+			admin.POST("/users/:id/verify-email", adminHandler.VerifyEmail)
+			admin.POST("/users/:id/reset-password", adminHandler.ResetPassword)
+			admin.POST("/users/:id/revoke-sessions", adminHandler.RevokeSessions)
+			admin.DELETE("/users/:id", adminHandler.DeleteUser)
+			admin.POST("/users/:id/restore", adminHandler.RestoreUser)
+			admin.POST("/users/:id/suspension", adminHandler.SuspendUser)
+			admin.DELETE("/users/:id/suspension", adminHandler.LiftSuspension)
+			admin.GET("/users/:id/suspensions", adminHandler.ListSuspensions)
+			admin.POST("/users/:id/impersonate", adminHandler.Impersonate)
+			admin.GET("/audit-logs", adminHandler.ListAuditLogs)
+			admin.GET("/jobs", jobHandler.ListJobs)
+			admin.POST("/jobs/:name/run", jobHandler.RunJob)
+		}
 	}
 
+	grpcServer := grpcserver.New(
+		grpcserver.NewUserServer(userRepo, authService, wsTicketService, tokenValidator, presenceService),
+		grpcserver.NewAuthenticator(tokenManager, serviceClientRepo),
+		grpcTLSConfig(tlsReloader),
+	)
+	grpcListener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		log.Fatalf("Failed to listen on gRPC port %s: %v", cfg.GRPCPort, err)
+	}
+	go func() {
+		log.Printf("gRPC server starting on port %s", cfg.GRPCPort)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("Failed to start gRPC server: %v", err)
+		}
+	}()
+
 	srv := &http.Server{
 		Addr:    ":" + cfg.HTTPPort,
 		Handler: router,
 	}
 
-	log.Printf("User service starting on port %s", cfg.HTTPPort)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if tlsReloader.Enabled() {
+		srv.TLSConfig = tlsReloader.ServerConfig()
+		log.Printf("User service starting on port %s with TLS", cfg.HTTPPort)
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("User service starting on port %s", cfg.HTTPPort)
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+func grpcTLSConfig(reloader *tlsconfig.Reloader) *tls.Config {
+	if !reloader.Enabled() {
+		return nil
+	}
+	return reloader.ServerConfig()
+}
@@ -0,0 +1,116 @@
+// Package cache is a small read-through JSON cache on top of Redis. A nil
+// *Cache, or one with a zero TTL, is disabled: every Get misses and writes
+// are no-ops, so callers never need to check whether caching is configured.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type Cache struct {
+	client redis.UniversalClient
+	name   string
+	ttl    time.Duration
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// New returns a cache whose keys are prefixed with name, which is also the
+// "cache" label of its metrics.
+func New(client redis.UniversalClient, name string, ttl time.Duration) *Cache {
+	return &Cache{client: client, name: name, ttl: ttl}
+}
+
+func (c *Cache) enabled() bool {
+	return c != nil && c.client != nil && c.ttl > 0
+}
+
+func (c *Cache) key(key string) string {
+	return c.name + ":" + key
+}
+
+// Get decodes the cached value of key into dst and reports whether it was
+// found. Redis errors count as misses so that callers fall back to the database.
+func (c *Cache) Get(ctx context.Context, key string, dst any) bool {
+	if !c.enabled() {
+		return false
+	}
+
+	data, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("[WARN] cache %s: get %s: %v", c.name, key, err)
+			requests.WithLabelValues(c.name, "error").Inc()
+		}
+		c.observe(false)
+		return false
+	}
+
+	if err := json.Unmarshal(data, dst); err != nil {
+		log.Printf("[WARN] cache %s: decode %s: %v", c.name, key, err)
+		c.observe(false)
+		return false
+	}
+
+	c.observe(true)
+	return true
+}
+
+// Set stores value under key for the cache TTL, or for ttl if that is shorter.
+func (c *Cache) Set(ctx context.Context, key string, value any, ttl time.Duration) {
+	if !c.enabled() {
+		return
+	}
+	if ttl <= 0 || ttl > c.ttl {
+		ttl = c.ttl
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("[WARN] cache %s: encode %s: %v", c.name, key, err)
+		return
+	}
+
+	if err := c.client.Set(ctx, c.key(key), data, ttl).Err(); err != nil {
+		log.Printf("[WARN] cache %s: set %s: %v", c.name, key, err)
+	}
+}
+
+// Delete invalidates keys. A failed delete leaves the entry to expire on its own.
+func (c *Cache) Delete(ctx context.Context, keys ...string) {
+	if !c.enabled() || len(keys) == 0 {
+		return
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.key(key)
+	}
+
+	if err := c.client.Del(ctx, prefixed...).Err(); err != nil {
+		log.Printf("[WARN] cache %s: delete %d keys: %v", c.name, len(keys), err)
+		invalidations.WithLabelValues(c.name, "error").Add(float64(len(keys)))
+		return
+	}
+	invalidations.WithLabelValues(c.name, "success").Add(float64(len(keys)))
+}
+
+func (c *Cache) observe(hit bool) {
+	if hit {
+		c.hits.Add(1)
+		requests.WithLabelValues(c.name, "hit").Inc()
+	} else {
+		c.misses.Add(1)
+		requests.WithLabelValues(c.name, "miss").Inc()
+	}
+
+	hits, misses := c.hits.Load(), c.misses.Load()
+	hitRatio.WithLabelValues(c.name).Set(float64(hits) / float64(hits+misses))
+}
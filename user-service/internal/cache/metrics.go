@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Cache lookups by result: hit, miss, or error (also counted as a miss).",
+	}, []string{"cache", "result"})
+
+	invalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_invalidations_total",
+		Help: "Invalidated cache keys by result.",
+	}, []string{"cache", "result"})
+
+	hitRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cache_hit_ratio",
+		Help: "Share of lookups served from the cache since the process started.",
+	}, []string{"cache"})
+)
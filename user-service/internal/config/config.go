@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
 	HTTPPort   string
@@ -13,6 +18,94 @@ type Config struct {
 	RedisHost  string
 	RedisPort  string
 	JWTSecret  string
+
+	// A session ends SessionIdleTimeout after its last refresh, and in any case
+	// SessionAbsoluteLifetime after sign-in. A zero idle timeout disables it.
+	SessionAbsoluteLifetime time.Duration
+	SessionIdleTimeout      time.Duration
+	// SessionMaxPerUser caps active sessions per user; 0, the default, means
+	// unlimited. SessionEvictionPolicy is reject, evict_oldest or evict_lru
+	// and decides what a sign-in past the cap does.
+	SessionMaxPerUser     int
+	SessionEvictionPolicy string
+	// RevocationCacheTTL is how long AuthMiddleware may reuse a revocation
+	// lookup; revocations made by other instances can take this long to apply.
+	RevocationCacheTTL time.Duration
+	// UserCacheTTL, SessionCacheTTL and ServiceClientCacheTTL bound how long
+	// user profiles, sessions and service clients stay in the Redis
+	// read-through cache; 0 disables a cache.
+	UserCacheTTL          time.Duration
+	SessionCacheTTL       time.Duration
+	ServiceClientCacheTTL time.Duration
+
+	// Cookie mode lets browser clients keep the refresh token in an HttpOnly
+	// cookie. CookieSameSite is strict, lax or none; none requires CookieSecure.
+	CookieAuthEnabled bool
+	CookieDomain      string
+	CookieSecure      bool
+	CookieSameSite    string
+	RefreshCookieName string
+	CSRFCookieName    string
+
+	// DPoPProofMaxAge is how far a DPoP proof's iat may lie from the server
+	// clock; replayed proofs are remembered twice as long.
+	DPoPProofMaxAge time.Duration
+
+	// DeviceVerificationURI is the page of the web client where users enter
+	// the user code of a device signing in with the device authorization grant.
+	DeviceVerificationURI string
+	DeviceCodeLifetime    time.Duration
+	DevicePollInterval    time.Duration
+
+	// QR login tickets expire after QRLoginTTL; a waiting web client is
+	// answered after QRLoginPollTimeout at the latest and polls again.
+	QRLoginTTL         time.Duration
+	QRLoginPollTimeout time.Duration
+
+	// WSTicketTTL is how long a WebSocket connection ticket may be redeemed.
+	WSTicketTTL time.Duration
+
+	// ServiceTokenTTL is the lifetime of tokens issued to service clients.
+	ServiceTokenTTL time.Duration
+
+	// A presence connection is dropped PresenceHeartbeatTTL after its last
+	// heartbeat, and a connected user is away after PresenceAwayAfter without
+	// activity. Status changes without heartbeats are picked up every
+	// PresenceSweepInterval.
+	PresenceHeartbeatTTL  time.Duration
+	PresenceAwayAfter     time.Duration
+	PresenceSweepInterval time.Duration
+
+	// TLS is served on the HTTP and gRPC ports when TLSCertFile is set. The
+	// files are checked for changes every TLSReloadInterval. TLSClientAuth is
+	// none, request or require; client certificates are verified against
+	// TLSClientCAFile and map to service clients by their TLS identity.
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCAFile   string
+	TLSClientAuth     string
+	TLSReloadInterval time.Duration
+
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUser         string
+	SMTPPassword     string
+	SMTPFrom         string
+	AppBaseURL       string
+	MailTemplatesDir string
+
+	// GeoIPDatabasePath points to a GeoLite2/GeoIP2 City or Country MMDB file.
+	// GeoIP lookups are disabled when it is empty.
+	GeoIPDatabasePath   string
+	GeoIPReloadInterval time.Duration
+
+	// InstanceID names this replica, e.g. in the scheduler leader lock.
+	InstanceID       string
+	SchedulerEnabled bool
+	CleanupInterval  time.Duration
+	// DataRetention is how long expired sessions, verification tokens and
+	// retired signing keys are kept before the cleanup jobs delete them.
+	DataRetention time.Duration
 }
 
 func LoadConfig() *Config {
@@ -27,12 +120,105 @@ func LoadConfig() *Config {
 		RedisHost:  getEnv("REDIS_HOST", "localhost"),
 		RedisPort:  getEnv("REDIS_PORT", "6379"),
 		JWTSecret:  getEnv("JWT_SECRET", "your-super-secret-key"),
+
+		SessionAbsoluteLifetime: getEnvDuration("SESSION_ABSOLUTE_LIFETIME", 30*24*time.Hour),
+		SessionIdleTimeout:      getEnvDuration("SESSION_IDLE_TIMEOUT", 7*24*time.Hour),
+		SessionMaxPerUser:       getEnvInt("SESSION_MAX_PER_USER", 0),
+		SessionEvictionPolicy:   getEnv("SESSION_EVICTION_POLICY", "evict_lru"),
+		RevocationCacheTTL:      getEnvDuration("REVOCATION_CACHE_TTL", 2*time.Second),
+		UserCacheTTL:            getEnvDuration("USER_CACHE_TTL", 5*time.Minute),
+		SessionCacheTTL:         getEnvDuration("SESSION_CACHE_TTL", time.Minute),
+		ServiceClientCacheTTL:   getEnvDuration("SERVICE_CLIENT_CACHE_TTL", 30*time.Second),
+
+		CookieAuthEnabled: getEnvBool("COOKIE_AUTH_ENABLED", false),
+		CookieDomain:      getEnv("COOKIE_DOMAIN", ""),
+		CookieSecure:      getEnvBool("COOKIE_SECURE", true),
+		CookieSameSite:    getEnv("COOKIE_SAMESITE", "strict"),
+		RefreshCookieName: getEnv("REFRESH_COOKIE_NAME", "refresh_token"),
+		CSRFCookieName:    getEnv("CSRF_COOKIE_NAME", "csrf_token"),
+
+		DPoPProofMaxAge: getEnvDuration("DPOP_PROOF_MAX_AGE", time.Minute),
+
+		DeviceVerificationURI: getEnv("DEVICE_VERIFICATION_URI", "http://localhost:3000/device"),
+		DeviceCodeLifetime:    getEnvDuration("DEVICE_CODE_LIFETIME", 10*time.Minute),
+		DevicePollInterval:    getEnvDuration("DEVICE_POLL_INTERVAL", 5*time.Second),
+
+		QRLoginTTL:         getEnvDuration("QR_LOGIN_TTL", 2*time.Minute),
+		QRLoginPollTimeout: getEnvDuration("QR_LOGIN_POLL_TIMEOUT", 25*time.Second),
+
+		WSTicketTTL: getEnvDuration("WS_TICKET_TTL", 30*time.Second),
+
+		ServiceTokenTTL: getEnvDuration("SERVICE_TOKEN_TTL", 15*time.Minute),
+
+		PresenceHeartbeatTTL:  getEnvDuration("PRESENCE_HEARTBEAT_TTL", time.Minute),
+		PresenceAwayAfter:     getEnvDuration("PRESENCE_AWAY_AFTER", 5*time.Minute),
+		PresenceSweepInterval: getEnvDuration("PRESENCE_SWEEP_INTERVAL", 10*time.Second),
+
+		TLSCertFile:       getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:        getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:   getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:     getEnv("TLS_CLIENT_AUTH", "none"),
+		TLSReloadInterval: getEnvDuration("TLS_RELOAD_INTERVAL", time.Minute),
+
+		SMTPHost:         getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:         getEnvInt("SMTP_PORT", 587),
+		SMTPUser:         getEnv("SMTP_USER", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:         getEnv("SMTP_FROM", "Your new best chat application :))) <noreply@chat.com>"),
+		AppBaseURL:       getEnv("APP_BASE_URL", "localhost:8081"),
+		MailTemplatesDir: getEnv("MAIL_TEMPLATES_DIR", "internal/mailer/templates"),
+
+		GeoIPDatabasePath:   getEnv("GEOIP_DB_PATH", ""),
+		GeoIPReloadInterval: getEnvDuration("GEOIP_RELOAD_INTERVAL", time.Minute),
+
+		InstanceID:       getEnv("INSTANCE_ID", defaultInstanceID()),
+		SchedulerEnabled: getEnvBool("SCHEDULER_ENABLED", true),
+		CleanupInterval:  getEnvDuration("CLEANUP_INTERVAL", time.Hour),
+		DataRetention:    getEnvDuration("DATA_RETENTION", 30*24*time.Hour),
 	}
 }
 
+func (c *Config) DatabaseURL() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
+}
+
+func (c *Config) RedisAddr() string {
+	return fmt.Sprintf("%s:%s", c.RedisHost, c.RedisPort)
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"time"
+)
+
+// AdminListUsersQuery is bound from the query string of GET /admin/users.
+// Status filters by account state, not by the presence status of the user.
+type AdminListUsersQuery struct {
+	Status        string     `form:"status" binding:"omitempty,oneof=active deleted suspended"`
+	Verified      *bool      `form:"verified"`
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor        string     `form:"cursor"`
+	Limit         int        `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+type AdminUserListResponse struct {
+	Users      []*models.User `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type AdminUserDetailsResponse struct {
+	User             *models.User          `json:"user"`
+	Sessions         []*models.SessionInfo `json:"sessions"`
+	ActiveSuspension *models.Suspension    `json:"active_suspension,omitempty"`
+}
+
+type AdminCreateUserRequest struct {
+	Username    string `json:"username" binding:"required,min=3,max=50"`
+	Email       string `json:"email" binding:"required,email"`
+	Password    string `json:"password" binding:"required,min=8,max=32"`
+	DisplayName string `json:"display_name,omitempty" binding:"max=50"`
+	Role        string `json:"role,omitempty" binding:"omitempty,oneof=user admin"`
+	Verified    bool   `json:"verified,omitempty"`
+}
+
+type AdminSetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+type AdminResetPasswordRequest struct {
+	Password string `json:"password" binding:"required,min=8,max=32"`
+}
+
+// AdminSuspendUserRequest suspends until EndsAt, or permanently when EndsAt is omitted.
+type AdminSuspendUserRequest struct {
+	Reason string     `json:"reason" binding:"required,max=500"`
+	EndsAt *time.Time `json:"ends_at,omitempty"`
+}
+
+type SuspensionListResponse struct {
+	Suspensions []*models.Suspension `json:"suspensions"`
+	Total       int                  `json:"total"`
+}
+
+type AdminImpersonateRequest struct {
+	Reason          string `json:"reason" binding:"required,max=500"`
+	DurationMinutes int    `json:"duration_minutes,omitempty" binding:"omitempty,min=1,max=15"`
+}
+
+// ImpersonationResponse carries an access token only. There is deliberately no
+// refresh token, so the impersonation cannot outlive ExpiresIn.
+type ImpersonationResponse struct {
+	AccessToken string       `json:"access_token"`
+	ExpiresIn   int64        `json:"expires_in"`
+	ActorID     int64        `json:"actor_id"`
+	User        *models.User `json:"user"`
+}
+
+type AdminListAuditLogsQuery struct {
+	ActorID      int64  `form:"actor_id" binding:"omitempty,min=1"`
+	TargetUserID int64  `form:"target_user_id" binding:"omitempty,min=1"`
+	Action       string `form:"action"`
+	Cursor       string `form:"cursor"`
+	Limit        int    `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+type AuditLogListResponse struct {
+	Entries    []*models.AuditLog `json:"entries"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
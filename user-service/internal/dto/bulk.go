@@ -0,0 +1,68 @@
+package dto
+
+import "time"
+
+const (
+	BulkFormatCSV   = "csv"
+	BulkFormatJSONL = "jsonl"
+
+	ImportEmailNone         = "none"
+	ImportEmailVerification = "verification"
+	ImportEmailWelcome      = "welcome"
+)
+
+// ImportUserRow is one account in a bulk import file. Username, email and
+// display name follow the same rules as RegisterUserRequest. Each row carries
+// either an existing bcrypt PasswordHash or a plain Password to be hashed.
+type ImportUserRow struct {
+	Username     string     `json:"username" binding:"required,min=3,max=50"`
+	Email        string     `json:"email" binding:"required,email"`
+	Password     string     `json:"password,omitempty" binding:"required_without=PasswordHash,omitempty,min=8,max=32"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	DisplayName  string     `json:"display_name,omitempty" binding:"max=50"`
+	Verified     bool       `json:"verified,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+type AdminImportUsersQuery struct {
+	Format    string `form:"format" binding:"omitempty,oneof=csv jsonl"`
+	Emails    string `form:"emails" binding:"omitempty,oneof=none verification welcome"`
+	DryRun    bool   `form:"dry_run"`
+	BatchSize int    `form:"batch_size" binding:"omitempty,min=1,max=10000"`
+}
+
+type ImportRowError struct {
+	Line     int    `json:"line"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Error    string `json:"error"`
+}
+
+type ImportReport struct {
+	Total        int               `json:"total"`
+	Imported     int               `json:"imported"`
+	Failed       int               `json:"failed"`
+	EmailsSent   int               `json:"emails_sent"`
+	EmailsFailed int               `json:"emails_failed"`
+	DryRun       bool              `json:"dry_run"`
+	Errors       []*ImportRowError `json:"errors"`
+}
+
+type AdminExportUsersQuery struct {
+	Format              string `form:"format" binding:"omitempty,oneof=csv jsonl"`
+	Status              string `form:"status" binding:"omitempty,oneof=active deleted suspended"`
+	IncludePasswordHash bool   `form:"include_password_hash"`
+}
+
+// ExportUserRow is the exported form of a user. Its field names match
+// ImportUserRow so that an export can be imported elsewhere.
+type ExportUserRow struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	DisplayName  string    `json:"display_name,omitempty"`
+	Role         string    `json:"role"`
+	Verified     bool      `json:"verified"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package dto
+
+import "time"
+
+// DeviceCodeRequest and DeviceTokenRequest follow RFC 8628 and may be sent as
+// a form, like OAuth clients do, or as JSON.
+type DeviceCodeRequest struct {
+	ClientID string `form:"client_id" json:"client_id" binding:"max=100"`
+}
+
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type DeviceTokenRequest struct {
+	GrantType  string `form:"grant_type" json:"grant_type" binding:"required"`
+	DeviceCode string `form:"device_code" json:"device_code" binding:"required"`
+	ClientID   string `form:"client_id" json:"client_id"`
+	DeviceName string `form:"device_name" json:"device_name" binding:"max=100"`
+}
+
+type DeviceDecisionRequest struct {
+	UserCode string `json:"user_code" binding:"required"`
+}
+
+// DeviceAuthorizationInfo describes a pending request on the approval page so
+// that the user can tell whether it is their own device.
+type DeviceAuthorizationInfo struct {
+	UserCode   string    `json:"user_code"`
+	ClientID   *string   `json:"client_id,omitempty"`
+	DeviceType string    `json:"device_type"`
+	Browser    *string   `json:"browser,omitempty"`
+	OS         *string   `json:"os,omitempty"`
+	UserAgent  *string   `json:"user_agent,omitempty"`
+	IPAddress  *string   `json:"ip_address,omitempty"`
+	Location   *string   `json:"location,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -0,0 +1,28 @@
+package dto
+
+// IntrospectionRequest is an RFC 7662 token introspection request.
+type IntrospectionRequest struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+}
+
+// IntrospectionResponse describes an active token; an inactive one is only
+// {"active": false}. UserID, Email, SessionID, ActorID and Cnf are not part
+// of RFC 7662 and mirror the claims of the token.
+type IntrospectionResponse struct {
+	Active    bool              `json:"active"`
+	Subject   string            `json:"sub,omitempty"`
+	UserID    int64             `json:"user_id,omitempty"`
+	Username  string            `json:"username,omitempty"`
+	Email     string            `json:"email,omitempty"`
+	SessionID int64             `json:"sid,omitempty"`
+	ActorID   int64             `json:"act,omitempty"`
+	Cnf       *IntrospectionCnf `json:"cnf,omitempty"`
+	TokenType string            `json:"token_type,omitempty"`
+	ExpiresAt int64             `json:"exp,omitempty"`
+	IssuedAt  int64             `json:"iat,omitempty"`
+}
+
+type IntrospectionCnf struct {
+	JKT string `json:"jkt"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+import "github.com/zhanserikAmangeldi/user-service/internal/scheduler"
+
+type JobListResponse struct {
+	Instance string                 `json:"instance"`
+	Leader   bool                   `json:"leader"`
+	Jobs     []*scheduler.JobStatus `json:"jobs"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+type PresenceHeartbeatRequest struct {
+	// ConnectionID identifies the client connection, e.g. a browser tab, so
+	// that each can disconnect on its own.
+	ConnectionID string `json:"connection_id" binding:"required,max=64"`
+	// Active is false for heartbeats sent while the user is idle.
+	Active bool `json:"active"`
+}
+
+type PresenceDisconnectRequest struct {
+	ConnectionID string `json:"connection_id" binding:"required,max=64"`
+}
+
+type PresenceResponse struct {
+	Status string `json:"status"`
+	// HeartbeatInterval is how often, in seconds, to send heartbeats.
+	HeartbeatInterval int64 `json:"heartbeat_interval"`
+}
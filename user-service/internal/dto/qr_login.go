@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+type QRLoginTicketResponse struct {
+	// TicketID goes into the QR code. PollToken stays with the web client and
+	// proves that it is the one that created the ticket.
+	TicketID  string `json:"ticket_id"`
+	PollToken string `json:"poll_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type QRLoginPollRequest struct {
+	TicketID  string `json:"ticket_id" binding:"required"`
+	PollToken string `json:"poll_token" binding:"required"`
+}
+
+type QRLoginDecisionRequest struct {
+	TicketID string `json:"ticket_id" binding:"required"`
+}
+
+// QRLoginTicketInfo tells the mobile app which browser is asking to sign in.
+type QRLoginTicketInfo struct {
+	DeviceType string    `json:"device_type"`
+	Browser    *string   `json:"browser,omitempty"`
+	OS         *string   `json:"os,omitempty"`
+	IPAddress  *string   `json:"ip_address,omitempty"`
+	Location   *string   `json:"location,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
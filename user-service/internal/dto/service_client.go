@@ -0,0 +1,18 @@
+package dto
+
+// ClientCredentialsRequest is an RFC 6749 section 4.4 token request. The
+// client may authenticate with HTTP Basic instead of client_id and
+// client_secret, or with a client certificate.
+type ClientCredentialsRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type" binding:"required"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+	Scope        string `form:"scope" json:"scope"`
+}
+
+type ServiceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
@@ -2,6 +2,7 @@ package dto
 
 import (
 	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"time"
 )
 
 type RegisterUserRequest struct {
@@ -9,37 +10,56 @@ type RegisterUserRequest struct {
 	Email       string `json:"email" binding:"required,email"`
 	Password    string `json:"password" binding:"required,min=8,max=32"`
 	DisplayName string `json:"display_name,omitempty" binding:"max=50"`
+	DeviceName  string `json:"device_name,omitempty" binding:"max=100"`
 }
 
 type LoginRequest struct {
-	Login    string `json:"login" binding:"required"` // может быть email или username
-	Password string `json:"password" binding:"required"`
+	Login      string `json:"login" binding:"required"` // может быть email или username
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name,omitempty" binding:"max=100"`
 }
 
+// AuthResponse leaves out the refresh token in cookie mode, where it is sent
+// as a cookie that expires at RefreshExpiresAt instead.
 type AuthResponse struct {
 	AccessToken  string       `json:"access_token"`
-	RefreshToken string       `json:"refresh_token"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	TokenType    string       `json:"token_type"`
 	ExpiresIn    int64        `json:"expires_in"`
 	User         *models.User `json:"user"`
+
+	RefreshExpiresAt time.Time `json:"-"`
 }
 
+// RefreshTokenRequest and TokensRequest may omit the tokens in cookie mode,
+// where the refresh token comes from its cookie and the access token from
+// the Authorization header.
 type RefreshTokenRequest struct {
-	RefreshToken string `json:"refresh_token" binding:"required"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type TokensRequest struct {
-	AccessToken  string `json:"access_token" binding:"required"`
-	RefreshToken string `json:"refresh_token" binding:"required"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type UpdateUserRequest struct {
 	DisplayName *string `json:"display_name,omitempty" binding:"omitempty,max=100"`
 	AvatarURL   *string `json:"avatar_url,omitempty" binding:"omitempty,url"`
 	Bio         *string `json:"bio,omitempty" binding:"omitempty,max=500"`
-	Status      *string `json:"status,omitempty" binding:"omitempty,oneof=online offline away busy"`
+	// PresenceOverride is busy or invisible, or none to go back to the
+	// status derived from heartbeats.
+	PresenceOverride *string `json:"presence_override,omitempty" binding:"omitempty,oneof=none busy invisible"`
 }
 
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
 }
+
+type SuspendedErrorResponse struct {
+	Error   string     `json:"error"`
+	Message string     `json:"message,omitempty"`
+	Reason  string     `json:"reason,omitempty"`
+	EndsAt  *time.Time `json:"ends_at,omitempty"`
+}
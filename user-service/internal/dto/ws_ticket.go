@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+type WSTicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type ValidateWSTicketRequest struct {
+	Ticket string `json:"ticket" binding:"required"`
+}
+
+// WSTicketValidation names the user and session a WebSocket connection opened
+// with a ticket belongs to.
+type WSTicketValidation struct {
+	UserID    int64     `json:"user_id"`
+	SessionID int64     `json:"session_id"`
+	Username  string    `json:"username"`
+	IssuedAt  time.Time `json:"issued_at"`
+}
@@ -0,0 +1,171 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"github.com/oschwald/geoip2-golang"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Location is the approximate place an IP address belongs to.
+type Location struct {
+	City        string `json:"city,omitempty"`
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+}
+
+// String formats the location as "Almaty, KZ", or just the country when the
+// city is unknown.
+func (l *Location) String() string {
+	switch {
+	case l.City != "" && l.CountryCode != "":
+		return l.City + ", " + l.CountryCode
+	case l.CountryCode != "":
+		return l.CountryCode
+	default:
+		return l.City
+	}
+}
+
+// Locator resolves IP addresses against a local MMDB database (GeoLite2 or
+// GeoIP2 City/Country). A Locator without a database, including a nil one,
+// resolves nothing, so callers never need to check whether GeoIP is enabled.
+type Locator struct {
+	path string
+
+	mu      sync.RWMutex
+	db      *geoip2.Reader
+	modTime time.Time
+}
+
+// NewLocator opens the database at path. An empty path disables lookups. If the
+// file cannot be opened the returned Locator is still usable and picks the file
+// up on a later Reload.
+func NewLocator(path string) (*Locator, error) {
+	l := &Locator{path: path}
+	if path == "" {
+		return l, nil
+	}
+	return l, l.Reload()
+}
+
+// Enabled reports whether a database is currently loaded.
+func (l *Locator) Enabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.db != nil
+}
+
+// Lookup returns the location of ip, or nil if it is unknown, private or no
+// database is loaded.
+func (l *Locator) Lookup(ip string) *Location {
+	if l == nil {
+		return nil
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.IsLoopback() || parsed.IsPrivate() || parsed.IsUnspecified() {
+		return nil
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.db == nil {
+		return nil
+	}
+
+	record, err := l.db.City(parsed)
+	if err != nil {
+		return nil
+	}
+
+	location := &Location{
+		City:        record.City.Names["en"],
+		Country:     record.Country.Names["en"],
+		CountryCode: record.Country.IsoCode,
+	}
+	if location.City == "" && location.CountryCode == "" {
+		return nil
+	}
+	return location
+}
+
+// Reload reopens the database if the file changed since it was last loaded.
+func (l *Locator) Reload() error {
+	if l.path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+
+	l.mu.RLock()
+	unchanged := l.db != nil && info.ModTime().Equal(l.modTime)
+	l.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	db, err := geoip2.Open(l.path)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	old := l.db
+	l.db = db
+	l.modTime = info.ModTime()
+	l.mu.Unlock()
+
+	if old != nil {
+		_ = old.Close()
+	}
+
+	meta := db.Metadata()
+	log.Printf("[INFO] GeoIP database loaded: %s (%s, built %s)",
+		l.path, meta.DatabaseType, time.Unix(int64(meta.BuildEpoch), 0).UTC().Format(time.DateOnly))
+	return nil
+}
+
+// RunReloader checks the database file for changes every interval until ctx is
+// cancelled, so that a new database can be dropped in without a restart.
+func (l *Locator) RunReloader(ctx context.Context, interval time.Duration) {
+	if l.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("[ERROR] Failed to reload GeoIP database: %v", err)
+			}
+		}
+	}
+}
+
+func (l *Locator) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.db == nil {
+		return nil
+	}
+	err := l.db.Close()
+	l.db = nil
+	return err
+}
@@ -0,0 +1,178 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/tlsconfig"
+	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	userv1 "github.com/zhanserikAmangeldi/user-service/pkg/pb/user/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log"
+	"slices"
+	"strings"
+)
+
+// methodScopes lists the scope each UserService method requires. Methods of
+// other services, such as health checks and reflection, need no credentials.
+var methodScopes = map[string]string{
+	userv1.UserService_ValidateToken_FullMethodName:     models.ScopeTokensValidate,
+	userv1.UserService_ValidateWSTicket_FullMethodName:  models.ScopeWSTicketsValidate,
+	userv1.UserService_GetUser_FullMethodName:           models.ScopeUsersRead,
+	userv1.UserService_BatchGetUsers_FullMethodName:     models.ScopeUsersRead,
+	userv1.UserService_GetUserByUsername_FullMethodName: models.ScopeUsersRead,
+	userv1.UserService_WatchPresence_FullMethodName:     models.ScopeUsersRead,
+	userv1.UserService_Heartbeat_FullMethodName:         models.ScopePresenceWrite,
+	userv1.UserService_Disconnect_FullMethodName:        models.ScopePresenceWrite,
+	userv1.UserService_RevokeSession_FullMethodName:     models.ScopeSessionsRevoke,
+	userv1.UserService_RevokeAllSessions_FullMethodName: models.ScopeSessionsRevoke,
+}
+
+type serviceClientIDKey struct{}
+
+// ServiceClientID returns the service client making the call.
+func ServiceClientID(ctx context.Context) string {
+	clientID, _ := ctx.Value(serviceClientIDKey{}).(string)
+	return clientID
+}
+
+// Authenticator admits calls from service clients, like the ServiceAuth HTTP
+// middleware: with a service token in the "authorization" metadata, or with
+// a verified client certificate registered as a service client's TLS
+// identity.
+type Authenticator struct {
+	tokenManager *jwt.TokenManager
+	clients      *repository.ServiceClientRepository
+}
+
+func NewAuthenticator(tokenManager *jwt.TokenManager, clients *repository.ServiceClientRepository) *Authenticator {
+	return &Authenticator{tokenManager: tokenManager, clients: clients}
+}
+
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *Authenticator) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	scope, ok := methodScopes[method]
+	if !ok {
+		if strings.HasPrefix(method, "/"+userv1.UserService_ServiceDesc.ServiceName+"/") {
+			return nil, status.Error(codes.PermissionDenied, "method has no scope")
+		}
+		return ctx, nil
+	}
+
+	var clientID string
+	var err error
+	if token := bearerToken(ctx); token != "" {
+		clientID, err = a.authenticateToken(ctx, token, scope)
+	} else if identity := peerIdentity(ctx); identity != "" {
+		clientID, err = a.authenticateClientCert(ctx, identity, scope)
+	} else {
+		err = status.Error(codes.Unauthenticated, "service token or client certificate required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return context.WithValue(ctx, serviceClientIDKey{}, clientID), nil
+}
+
+func (a *Authenticator) authenticateToken(ctx context.Context, token, scope string) (string, error) {
+	claims, err := a.tokenManager.ValidateAccessToken(token)
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	if !claims.IsService() {
+		return "", status.Error(codes.PermissionDenied, "user tokens are not accepted")
+	}
+
+	client, err := a.clients.GetByClientIDCached(ctx, claims.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrServiceClientNotFound) {
+			return "", status.Error(codes.PermissionDenied, "unknown service client")
+		}
+		log.Printf("[ERROR] Failed to look up service client %s: %v", claims.ClientID, err)
+		return "", status.Error(codes.Internal, "internal error")
+	}
+	if client.IsDisabled() {
+		return "", status.Error(codes.PermissionDenied, "service client disabled")
+	}
+	if !claims.HasScope(scope) {
+		return "", status.Errorf(codes.PermissionDenied, "insufficient scope, %s required", scope)
+	}
+	return claims.ClientID, nil
+}
+
+func (a *Authenticator) authenticateClientCert(ctx context.Context, identity, scope string) (string, error) {
+	client, err := a.clients.GetByTLSIdentity(ctx, identity)
+	if err != nil {
+		if errors.Is(err, repository.ErrServiceClientNotFound) {
+			return "", status.Error(codes.PermissionDenied, "unknown client certificate")
+		}
+		log.Printf("[ERROR] Failed to look up service client for %s: %v", identity, err)
+		return "", status.Error(codes.Internal, "internal error")
+	}
+	if client.IsDisabled() {
+		return "", status.Error(codes.PermissionDenied, "service client disabled")
+	}
+	if !slices.Contains(client.Scopes, scope) {
+		return "", status.Errorf(codes.PermissionDenied, "insufficient scope, %s required", scope)
+	}
+	return client.ClientID, nil
+}
+
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return token
+}
+
+func peerIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	return tlsconfig.ClientIdentity(&tlsInfo.State)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
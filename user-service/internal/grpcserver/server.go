@@ -0,0 +1,344 @@
+// Package grpcserver exposes user-service to other services over gRPC.
+package grpcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/presence"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	userv1 "github.com/zhanserikAmangeldi/user-service/pkg/pb/user/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log"
+)
+
+const (
+	maxBatchGetUsers   = 100
+	maxWatchedPresence = 1000
+	maxConnectionID    = 64
+)
+
+// UserServer implements userv1.UserServiceServer on top of the same services
+// and repositories as the HTTP API.
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+
+	userRepo        *repository.UserRepository
+	authService     *service.AuthService
+	wsTicketService *service.WSTicketService
+	tokenValidator  *service.TokenValidator
+	presence        *service.PresenceService
+}
+
+func NewUserServer(
+	userRepo *repository.UserRepository,
+	authService *service.AuthService,
+	wsTicketService *service.WSTicketService,
+	tokenValidator *service.TokenValidator,
+	presenceService *service.PresenceService,
+) *UserServer {
+	return &UserServer{
+		userRepo:        userRepo,
+		authService:     authService,
+		wsTicketService: wsTicketService,
+		tokenValidator:  tokenValidator,
+		presence:        presenceService,
+	}
+}
+
+// New returns a gRPC server with the user service, health checking and
+// reflection registered. tlsConfig may be nil to serve plaintext.
+func New(users *UserServer, auth *Authenticator, tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(auth.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(auth.StreamInterceptor),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	userv1.RegisterUserServiceServer(server, users)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(userv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+
+	return server
+}
+
+// ValidateToken accepts the same access tokens as the HTTP AuthMiddleware.
+// Revocation is not enforced when the revocation store is unreachable.
+func (s *UserServer) ValidateToken(ctx context.Context, req *userv1.ValidateTokenRequest) (*userv1.ValidateTokenResponse, error) {
+	if req.GetAccessToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "access_token is required")
+	}
+
+	claims, err := s.tokenValidator.Validate(ctx, req.GetAccessToken())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAccountSuspended):
+			return nil, status.Error(codes.PermissionDenied, "account suspended")
+		case errors.Is(err, service.ErrTokenRevoked):
+			return nil, status.Error(codes.Unauthenticated, "token revoked")
+		default:
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+		}
+	}
+
+	return &userv1.ValidateTokenResponse{
+		UserId:         claims.UserId,
+		Username:       claims.Username,
+		Email:          claims.Email,
+		SessionId:      claims.SessionID,
+		ImpersonatorId: claims.ActorID(),
+		DpopJkt:        claims.KeyThumbprint(),
+		ExpiresAt:      timestamppb.New(claims.ExpiresAt.Time),
+	}, nil
+}
+
+func (s *UserServer) ValidateWSTicket(ctx context.Context, req *userv1.ValidateWSTicketRequest) (*userv1.ValidateWSTicketResponse, error) {
+	if req.GetTicket() == "" {
+		return nil, status.Error(codes.InvalidArgument, "ticket is required")
+	}
+
+	validation, err := s.wsTicketService.Validate(ctx, req.GetTicket())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidWSTicket):
+			return nil, status.Error(codes.Unauthenticated, "the ticket is invalid, expired or already used")
+		case errors.Is(err, service.ErrWSTicketRevoked):
+			return nil, status.Error(codes.Unauthenticated, "the session of the ticket has been revoked")
+		default:
+			return nil, internalError("validate websocket ticket", err)
+		}
+	}
+
+	return &userv1.ValidateWSTicketResponse{
+		UserId:    validation.UserID,
+		SessionId: validation.SessionID,
+		Username:  validation.Username,
+		IssuedAt:  timestamppb.New(validation.IssuedAt),
+	}, nil
+}
+
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, req.GetUserId())
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, internalError("get user", err)
+	}
+
+	s.presence.Resolve(ctx, user, false)
+	return &userv1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+func (s *UserServer) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	if len(req.GetUserIds()) > maxBatchGetUsers {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d user IDs per call", maxBatchGetUsers)
+	}
+
+	resp := &userv1.BatchGetUsersResponse{}
+	seen := make(map[int64]bool, len(req.GetUserIds()))
+	for _, id := range req.GetUserIds() {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		user, err := s.userRepo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				continue
+			}
+			return nil, internalError("get users", err)
+		}
+		s.presence.Resolve(ctx, user, false)
+		resp.Users = append(resp.Users, toProtoUser(user))
+	}
+
+	return resp, nil
+}
+
+func (s *UserServer) GetUserByUsername(ctx context.Context, req *userv1.GetUserByUsernameRequest) (*userv1.GetUserResponse, error) {
+	user, err := s.userRepo.GetByUsername(ctx, req.GetUsername())
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, internalError("get user by username", err)
+	}
+
+	s.presence.Resolve(ctx, user, false)
+	return &userv1.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+// WatchPresence subscribes before reading the current presence, so that no
+// change is lost in between; a change that races the snapshot may be sent
+// twice.
+func (s *UserServer) WatchPresence(req *userv1.WatchPresenceRequest, stream userv1.UserService_WatchPresenceServer) error {
+	userIDs := req.GetUserIds()
+	if len(userIDs) == 0 {
+		return status.Error(codes.InvalidArgument, "user_ids is required")
+	}
+	if len(userIDs) > maxWatchedPresence {
+		return status.Errorf(codes.InvalidArgument, "at most %d user IDs per watch", maxWatchedPresence)
+	}
+
+	ctx := stream.Context()
+
+	sub, err := s.presence.Subscribe(ctx, userIDs)
+	if err != nil {
+		return internalError("subscribe to presence", err)
+	}
+	defer sub.Close()
+
+	seen := make(map[int64]bool, len(userIDs))
+	for _, id := range userIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		user, err := s.userRepo.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				continue
+			}
+			return internalError("get user", err)
+		}
+		s.presence.Resolve(ctx, user, false)
+		update := presence.Update{UserID: user.ID, Status: user.Status, LastSeenAt: user.LastSeenAt}
+		if err := stream.Send(toProtoPresence(update)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		updates, err := sub.Next(ctx)
+		if err != nil {
+			return status.FromContextError(err).Err()
+		}
+		for _, update := range updates {
+			if err := stream.Send(toProtoPresence(update)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *UserServer) Heartbeat(ctx context.Context, req *userv1.HeartbeatRequest) (*userv1.HeartbeatResponse, error) {
+	if err := validateConnection(req.GetUserId(), req.GetConnectionId()); err != nil {
+		return nil, err
+	}
+
+	presenceStatus, err := s.presence.Heartbeat(ctx, req.GetUserId(), req.GetConnectionId(), req.GetActive())
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, internalError("record heartbeat", err)
+	}
+
+	return &userv1.HeartbeatResponse{
+		Status:            presenceStatus,
+		HeartbeatInterval: durationpb.New(s.presence.HeartbeatInterval()),
+	}, nil
+}
+
+func (s *UserServer) Disconnect(ctx context.Context, req *userv1.DisconnectRequest) (*userv1.DisconnectResponse, error) {
+	if err := validateConnection(req.GetUserId(), req.GetConnectionId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.presence.Disconnect(ctx, req.GetUserId(), req.GetConnectionId()); err != nil {
+		return nil, internalError("disconnect", err)
+	}
+	return &userv1.DisconnectResponse{}, nil
+}
+
+func (s *UserServer) RevokeSession(ctx context.Context, req *userv1.RevokeSessionRequest) (*userv1.RevokeSessionResponse, error) {
+	if err := s.authService.RevokeSession(ctx, req.GetUserId(), req.GetSessionId()); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+		return nil, internalError("revoke session", err)
+	}
+
+	log.Printf("[INFO] Session %d of userID=%d revoked by service %s", req.GetSessionId(), req.GetUserId(), ServiceClientID(ctx))
+	return &userv1.RevokeSessionResponse{}, nil
+}
+
+func (s *UserServer) RevokeAllSessions(ctx context.Context, req *userv1.RevokeAllSessionsRequest) (*userv1.RevokeAllSessionsResponse, error) {
+	if err := s.authService.LogoutAll(ctx, req.GetUserId()); err != nil {
+		return nil, internalError("revoke sessions", err)
+	}
+
+	log.Printf("[INFO] All sessions of userID=%d revoked by service %s", req.GetUserId(), ServiceClientID(ctx))
+	return &userv1.RevokeAllSessionsResponse{}, nil
+}
+
+func validateConnection(userID int64, connectionID string) error {
+	if userID <= 0 {
+		return status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if connectionID == "" || len(connectionID) > maxConnectionID {
+		return status.Errorf(codes.InvalidArgument, "connection_id must be 1 to %d characters", maxConnectionID)
+	}
+	return nil
+}
+
+func internalError(action string, err error) error {
+	log.Printf("[ERROR] gRPC: failed to %s: %v", action, err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toProtoUser(user *models.User) *userv1.User {
+	pb := &userv1.User{
+		Id:         user.ID,
+		Username:   user.Username,
+		Email:      user.Email,
+		Status:     user.Status,
+		Role:       user.Role,
+		IsVerified: user.IsVerified,
+		CreatedAt:  timestamppb.New(user.CreatedAt),
+	}
+	if user.DisplayName != nil {
+		pb.DisplayName = *user.DisplayName
+	}
+	if user.AvatarURL != nil {
+		pb.AvatarUrl = *user.AvatarURL
+	}
+	if user.Bio != nil {
+		pb.Bio = *user.Bio
+	}
+	if user.LastSeenAt != nil {
+		pb.LastSeenAt = timestamppb.New(*user.LastSeenAt)
+	}
+	return pb
+}
+
+func toProtoPresence(update presence.Update) *userv1.PresenceUpdate {
+	pb := &userv1.PresenceUpdate{
+		UserId: update.UserID,
+		Status: update.Status,
+	}
+	if update.LastSeenAt != nil {
+		pb.LastSeenAt = timestamppb.New(*update.LastSeenAt)
+	}
+	return pb
+}
@@ -0,0 +1,413 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"log"
+	"net/http"
+	"time"
+)
+
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminHandler(adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+func getActor(c *gin.Context) service.Actor {
+	userAgent, ip := getClientInfo(c)
+	return service.Actor{
+		UserID:    middleware.GetUserID(c),
+		IPAddress: ip,
+		UserAgent: userAgent,
+	}
+}
+
+func bindUserIDParam(c *gin.Context) (int64, bool) {
+	var uriParam struct {
+		ID int64 `uri:"id" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindUri(&uriParam); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid user ID",
+		})
+		return 0, false
+	}
+
+	return uriParam.ID, true
+}
+
+func writeAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "user_not_found",
+			Message: "User not found",
+		})
+	case errors.Is(err, service.ErrAlreadyUserExists):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{
+			Error:   "user_exists",
+			Message: "User with this email or username already exists",
+		})
+	case errors.Is(err, repository.ErrSuspensionNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "suspension_not_found",
+			Message: "User has no active suspension",
+		})
+	case errors.Is(err, service.ErrCannotImpersonate):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "impersonation_forbidden",
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrAccountSuspended):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{
+			Error:   "account_suspended",
+			Message: err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidCursor),
+		errors.Is(err, service.ErrCannotSuspendSelf),
+		errors.Is(err, service.ErrCannotChangeOwnRole),
+		errors.Is(err, service.ErrSuspensionEndInPast):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "internal_error",
+		})
+	}
+}
+
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var query dto.AdminListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	users, err := h.adminService.ListUsers(c.Request.Context(), &query)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *AdminHandler) CreateUser(c *gin.Context) {
+	var req dto.AdminCreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.adminService.CreateUser(c.Request.Context(), getActor(c), &req)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.AdminSetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.adminService.SetRole(c.Request.Context(), getActor(c), userID, req.Role); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role updated"})
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	details, err := h.adminService.GetUserDetails(c.Request.Context(), userID)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+func (h *AdminHandler) VerifyEmail(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.VerifyEmail(c.Request.Context(), getActor(c), userID); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+}
+
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.AdminResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.adminService.ResetPassword(c.Request.Context(), getActor(c), userID, req.Password); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+func (h *AdminHandler) RevokeSessions(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.RevokeSessions(c.Request.Context(), getActor(c), userID); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "all sessions revoked"})
+}
+
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.DeleteUser(c.Request.Context(), getActor(c), userID); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+}
+
+func (h *AdminHandler) RestoreUser(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.RestoreUser(c.Request.Context(), getActor(c), userID); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user restored"})
+}
+
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.AdminSuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	suspension, err := h.adminService.SuspendUser(c.Request.Context(), getActor(c), userID, &req)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, suspension)
+}
+
+func (h *AdminHandler) LiftSuspension(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.adminService.LiftSuspension(c.Request.Context(), getActor(c), userID); err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "suspension lifted"})
+}
+
+func (h *AdminHandler) ListSuspensions(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	suspensions, err := h.adminService.ListSuspensions(c.Request.Context(), userID)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, suspensions)
+}
+
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	userID, ok := bindUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.AdminImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.adminService.Impersonate(c.Request.Context(), getActor(c), userID, &req)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	var query dto.AdminListAuditLogsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	entries, err := h.adminService.ListAuditLogs(c.Request.Context(), &query)
+	if err != nil {
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// ImportUsers reads a CSV or JSONL file from the raw request body. The format
+// comes from the query string, or from the Content-Type header when omitted.
+func (h *AdminHandler) ImportUsers(c *gin.Context) {
+	var query dto.AdminImportUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if query.Format == "" {
+		switch c.ContentType() {
+		case "application/x-ndjson", "application/jsonl", "application/json-lines":
+			query.Format = dto.BulkFormatJSONL
+		default:
+			query.Format = dto.BulkFormatCSV
+		}
+	}
+
+	report, err := h.adminService.ImportUsers(c.Request.Context(), getActor(c), c.Request.Body, service.ImportOptions{
+		Format:    query.Format,
+		Emails:    query.Emails,
+		DryRun:    query.DryRun,
+		BatchSize: query.BatchSize,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedFormat) || errors.Is(err, service.ErrInvalidImportFile) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:   "invalid_import_file",
+				Message: err.Error(),
+			})
+			return
+		}
+		writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *AdminHandler) ExportUsers(c *gin.Context) {
+	var query dto.AdminExportUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if query.Format == "" {
+		query.Format = dto.BulkFormatCSV
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if query.Format == dto.BulkFormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.%s"`,
+		time.Now().UTC().Format("20060102-150405"), query.Format))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent once the first page is flushed, so a failure
+	// halfway through can only be logged and the response cut short.
+	if err := h.adminService.ExportUsers(c.Request.Context(), getActor(c), c.Writer, &query); err != nil {
+		log.Printf("[ERROR] User export failed: %v", err)
+		_ = c.Error(err)
+	}
+}
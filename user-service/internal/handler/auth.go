@@ -6,16 +6,61 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/zhanserikAmangeldi/user-service/internal/dto"
 	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
 	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"github.com/zhanserikAmangeldi/user-service/pkg/dpop"
+	"io"
 	"net/http"
+	"strings"
 )
 
 type AuthHandler struct {
 	authService *service.AuthService
+	cookies     CookieConfig
+	proofs      *dpop.Verifier
 }
 
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService *service.AuthService, cookies CookieConfig, proofs *dpop.Verifier) *AuthHandler {
+	return &AuthHandler{authService: authService, cookies: cookies, proofs: proofs}
+}
+
+// requestDPoPKey returns the key thumbprint of the request's DPoP proof, or ""
+// if it has none. On a bad proof it responds and returns ok == false.
+func requestDPoPKey(c *gin.Context, proofs *dpop.Verifier) (string, bool) {
+	if c.GetHeader(dpop.HeaderName) == "" {
+		return "", true
+	}
+
+	thumbprint, err := middleware.VerifyDPoPProof(c, proofs, "")
+	if err != nil {
+		middleware.AbortInvalidDPoP(c, err)
+		return "", false
+	}
+	return thumbprint, true
+}
+
+// bindOptionalJSON binds the body if there is one; in cookie mode refresh and
+// logout requests may come without a body.
+func bindOptionalJSON(c *gin.Context, obj any) error {
+	if err := c.ShouldBindJSON(obj); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// writeAuthResponse sends authResp, moving the refresh token into a cookie if
+// useCookies is set.
+func (h *AuthHandler) writeAuthResponse(c *gin.Context, status int, authResp *dto.AuthResponse, useCookies bool) {
+	if useCookies {
+		if err := h.setAuthCookies(c, authResp); err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "internal_error",
+			})
+			return
+		}
+	}
+
+	c.JSON(status, authResp)
 }
 
 func getClientInfo(c *gin.Context) (*string, *string) {
@@ -35,6 +80,22 @@ func getClientInfo(c *gin.Context) (*string, *string) {
 	return userAgentStr, ipPtr
 }
 
+// writeSuspendedError responds with account_suspended if err is a suspension.
+func writeSuspendedError(c *gin.Context, err error) bool {
+	var suspended *service.SuspendedError
+	if !errors.As(err, &suspended) {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, dto.SuspendedErrorResponse{
+		Error:   "account_suspended",
+		Message: "Your account has been suspended",
+		Reason:  suspended.Reason,
+		EndsAt:  suspended.EndsAt,
+	})
+	return true
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req dto.RegisterUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -45,8 +106,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	dpopKey, ok := requestDPoPKey(c, h.proofs)
+	if !ok {
+		return
+	}
+
 	userAgent, ip := getClientInfo(c)
-	authResp, err := h.authService.Register(c.Request.Context(), &req, userAgent, ip)
+	authResp, err := h.authService.Register(c.Request.Context(), &req, userAgent, ip, dpopKey)
 	if err != nil {
 		if errors.Is(err, service.ErrAlreadyUserExists) {
 			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
@@ -62,7 +128,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, authResp)
+	h.writeAuthResponse(c, http.StatusCreated, authResp, h.wantsCookies(c))
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -75,8 +141,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	dpopKey, ok := requestDPoPKey(c, h.proofs)
+	if !ok {
+		return
+	}
+
 	userAgent, ip := getClientInfo(c)
-	authResp, err := h.authService.Login(c.Request.Context(), &req, userAgent, ip)
+	authResp, err := h.authService.Login(c.Request.Context(), &req, userAgent, ip, dpopKey)
 	if err != nil {
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
@@ -85,6 +156,16 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			})
 			return
 		}
+		if writeSuspendedError(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionLimitReached) {
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error:   "session_limit_reached",
+				Message: "Sign out of another device before signing in on this one",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 			Error:   "internal_error",
 			Message: "Failed to login",
@@ -92,12 +173,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, authResp)
+	h.writeAuthResponse(c, http.StatusOK, authResp, h.wantsCookies(c))
 }
 
+// RefreshToken takes the refresh token from the body, or from the refresh
+// cookie in cookie mode, in which case the rotated token is set as a cookie.
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req dto.RefreshTokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindOptionalJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
 			Error:   "validation_error",
 			Message: err.Error(),
@@ -105,9 +188,37 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
+	fromCookie := false
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.refreshCookie(c)
+		fromCookie = req.RefreshToken != ""
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: "refresh_token is required",
+		})
+		return
+	}
+
+	dpopKey, ok := requestDPoPKey(c, h.proofs)
+	if !ok {
+		return
+	}
+
 	userAgent, ip := getClientInfo(c)
-	authResp, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken, userAgent, ip)
+	authResp, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken, userAgent, ip, dpopKey)
 	if err != nil {
+		if errors.Is(err, service.ErrDPoPKeyMismatch) {
+			middleware.AbortInvalidDPoP(c, err)
+			return
+		}
+		if fromCookie {
+			h.clearAuthCookies(c)
+		}
+		if writeSuspendedError(c, err) {
+			return
+		}
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error:   "invalid_token",
 			Message: err.Error(),
@@ -115,12 +226,14 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, authResp)
+	h.writeAuthResponse(c, http.StatusOK, authResp, fromCookie)
 }
 
+// Logout takes the tokens from the body or, in cookie mode, the refresh token
+// from its cookie and the access token from the Authorization header.
 func (h *AuthHandler) Logout(c *gin.Context) {
 	var req dto.TokensRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindOptionalJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
 			Error:   "validation_error",
 			Message: err.Error(),
@@ -128,7 +241,28 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
+	fromCookie := false
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.refreshCookie(c)
+		fromCookie = req.RefreshToken != ""
+	}
+	if req.AccessToken == "" {
+		if _, token, found := strings.Cut(c.GetHeader("Authorization"), " "); found {
+			req.AccessToken = token
+		}
+	}
+	if req.RefreshToken == "" || req.AccessToken == "" {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: "access_token and refresh_token are required",
+		})
+		return
+	}
+
 	err := h.authService.Logout(c.Request.Context(), req.RefreshToken, req.AccessToken)
+	if fromCookie {
+		h.clearAuthCookies(c)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 			Error:   "internal_server",
@@ -166,6 +300,78 @@ func (h *AuthHandler) LogoutAll(c *gin.Context) {
 	})
 }
 
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return
+	}
+
+	var uriParam struct {
+		ID int64 `uri:"id" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindUri(&uriParam); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: "Invalid session ID",
+		})
+		return
+	}
+
+	err := h.authService.RevokeSession(c.Request.Context(), userID, uriParam.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{
+				Error:   "session_not_found",
+				Message: "Session not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to revoke session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+	})
+}
+
+func (h *AuthHandler) LogoutOthers(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return
+	}
+
+	revoked, err := h.authService.LogoutOthers(c.Request.Context(), userID, middleware.GetSessionID(c))
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error:   "session_not_found",
+				Message: "The current token does not belong to an active session",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to logout from other devices",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out from other devices successfully",
+		"revoked": revoked,
+	})
+}
+
 func (h *AuthHandler) GetActiveSessions(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 	if userID == 0 {
@@ -177,7 +383,7 @@ func (h *AuthHandler) GetActiveSessions(c *gin.Context) {
 
 	currentRefreshToken := c.Query("current_token")
 
-	sessions, err := h.authService.GetActiveSessions(c.Request.Context(), userID, currentRefreshToken)
+	sessions, err := h.authService.GetActiveSessions(c.Request.Context(), userID, currentRefreshToken, middleware.GetSessionID(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 			Error: "internal_error",
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// authModeHeader lets browser clients opt into cookie mode on register and login.
+	authModeHeader = "X-Auth-Mode"
+	authModeCookie = "cookie"
+
+	// refreshCookiePath keeps the refresh token away from every endpoint but
+	// the auth ones that consume it.
+	refreshCookiePath = "/api/v1/auth"
+)
+
+// CookieConfig describes the optional cookie mode, in which the refresh token
+// is kept in an HttpOnly cookie and paired with a double-submit CSRF cookie
+// that the client echoes in the X-CSRF-Token header.
+type CookieConfig struct {
+	Enabled       bool
+	Domain        string
+	Secure        bool
+	SameSite      http.SameSite
+	RefreshCookie string
+	CSRFCookie    string
+}
+
+// ParseSameSite maps strict, lax and none to their http.SameSite values.
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("unknown SameSite mode %q", value)
+}
+
+// wantsCookies reports whether a register or login request asked for cookie mode.
+func (h *AuthHandler) wantsCookies(c *gin.Context) bool {
+	return h.cookies.Enabled && strings.EqualFold(c.GetHeader(authModeHeader), authModeCookie)
+}
+
+// refreshCookie returns the refresh token cookie if cookie mode is enabled.
+func (h *AuthHandler) refreshCookie(c *gin.Context) string {
+	if !h.cookies.Enabled {
+		return ""
+	}
+	token, err := c.Cookie(h.cookies.RefreshCookie)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// setAuthCookies moves the refresh token from resp into a cookie and issues a
+// fresh CSRF token alongside it.
+func (h *AuthHandler) setAuthCookies(c *gin.Context, resp *dto.AuthResponse) error {
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		return err
+	}
+
+	maxAge := int(time.Until(resp.RefreshExpiresAt).Seconds())
+	h.setCookie(c, h.cookies.RefreshCookie, resp.RefreshToken, refreshCookiePath, true, maxAge)
+	h.setCookie(c, h.cookies.CSRFCookie, csrfToken, "/", false, maxAge)
+
+	resp.RefreshToken = ""
+	return nil
+}
+
+func (h *AuthHandler) clearAuthCookies(c *gin.Context) {
+	h.setCookie(c, h.cookies.RefreshCookie, "", refreshCookiePath, true, -1)
+	h.setCookie(c, h.cookies.CSRFCookie, "", "/", false, -1)
+}
+
+func (h *AuthHandler) setCookie(c *gin.Context, name, value, path string, httpOnly bool, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   h.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   h.cookies.Secure,
+		HttpOnly: httpOnly,
+		SameSite: h.cookies.SameSite,
+	})
+}
+
+func newCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
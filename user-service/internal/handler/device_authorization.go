@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"github.com/zhanserikAmangeldi/user-service/pkg/dpop"
+	"net/http"
+)
+
+type DeviceAuthHandler struct {
+	deviceAuthService *service.DeviceAuthService
+	proofs            *dpop.Verifier
+}
+
+func NewDeviceAuthHandler(deviceAuthService *service.DeviceAuthService, proofs *dpop.Verifier) *DeviceAuthHandler {
+	return &DeviceAuthHandler{deviceAuthService: deviceAuthService, proofs: proofs}
+}
+
+// RequestCode is the RFC 8628 device authorization endpoint.
+func (h *DeviceAuthHandler) RequestCode(c *gin.Context) {
+	var req dto.DeviceCodeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userAgent, ip := getClientInfo(c)
+	resp, err := h.deviceAuthService.StartAuthorization(c.Request.Context(), &req, userAgent, ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "server_error",
+			Message: "Failed to start device authorization",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+// Token is polled by the device until the user approves or denies it. Errors
+// use the RFC 8628 codes, which clients act upon.
+func (h *DeviceAuthHandler) Token(c *gin.Context) {
+	var req dto.DeviceTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	dpopKey, ok := requestDPoPKey(c, h.proofs)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	authResp, err := h.deviceAuthService.PollToken(c.Request.Context(), &req, dpopKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAuthorizationPending):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:   err.Error(),
+				Message: "The user has not yet approved the device",
+			})
+		case errors.Is(err, service.ErrSlowDown):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:   err.Error(),
+				Message: "Polling too fast, wait 5 seconds longer between requests",
+			})
+		case errors.Is(err, service.ErrAccessDenied):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:   err.Error(),
+				Message: "The user denied the device",
+			})
+		case errors.Is(err, service.ErrDeviceCodeExpired):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:   err.Error(),
+				Message: "The device code has expired, request a new one",
+			})
+		case errors.Is(err, service.ErrInvalidDeviceCode), errors.Is(err, service.ErrUnsupportedGrantType):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: err.Error(),
+			})
+		case errors.Is(err, service.ErrSessionLimitReached):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error:   "session_limit_reached",
+				Message: "Sign out of another device before signing in on this one",
+			})
+		default:
+			if writeSuspendedError(c, err) {
+				return
+			}
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "server_error",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, authResp)
+}
+
+// GetPending shows a signed-in user which device is asking for access.
+func (h *DeviceAuthHandler) GetPending(c *gin.Context) {
+	info, err := h.deviceAuthService.GetPending(c.Request.Context(), c.Query("user_code"))
+	if err != nil {
+		h.writeDecisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *DeviceAuthHandler) Approve(c *gin.Context) {
+	h.decide(c, true)
+}
+
+func (h *DeviceAuthHandler) Deny(c *gin.Context) {
+	h.decide(c, false)
+}
+
+func (h *DeviceAuthHandler) decide(c *gin.Context, approve bool) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return
+	}
+
+	var req dto.DeviceDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.deviceAuthService.Decide(c.Request.Context(), userID, req.UserCode, approve); err != nil {
+		h.writeDecisionError(c, err)
+		return
+	}
+
+	message := "Device denied"
+	if approve {
+		message = "Device approved, it will be signed in shortly"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func (h *DeviceAuthHandler) writeDecisionError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrUserCodeNotFound) {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "user_code_not_found",
+			Message: "The code is invalid or has expired",
+		})
+		return
+	}
+	if writeSuspendedError(c, err) {
+		return
+	}
+	c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+		Error: "internal_error",
+	})
+}
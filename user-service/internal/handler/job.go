@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/scheduler"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"log"
+	"net/http"
+)
+
+type JobHandler struct {
+	scheduler    *scheduler.Scheduler
+	adminService *service.AdminService
+}
+
+func NewJobHandler(scheduler *scheduler.Scheduler, adminService *service.AdminService) *JobHandler {
+	return &JobHandler{scheduler: scheduler, adminService: adminService}
+}
+
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, dto.JobListResponse{
+		Instance: h.scheduler.InstanceID(),
+		Leader:   h.scheduler.IsLeader(),
+		Jobs:     h.scheduler.Jobs(),
+	})
+}
+
+// RunJob runs a job right away on the instance that received the request and
+// responds once it has finished.
+func (h *JobHandler) RunJob(c *gin.Context) {
+	name := c.Param("name")
+
+	status, err := h.scheduler.Trigger(c.Request.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, scheduler.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{
+				Error:   "job_not_found",
+				Message: "Job not found",
+			})
+		case errors.Is(err, scheduler.ErrJobRunning):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error:   "job_running",
+				Message: "Job is already running",
+			})
+		case errors.Is(err, scheduler.ErrLockUnavailable):
+			log.Printf("[ERROR] Failed to run job %s: %v", name, err)
+			c.JSON(http.StatusServiceUnavailable, dto.ErrorResponse{
+				Error:   "lock_unavailable",
+				Message: "Job lock could not be taken, try again later",
+			})
+		default:
+			log.Printf("[ERROR] Failed to run job %s: %v", name, err)
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "internal_error",
+			})
+		}
+		return
+	}
+
+	h.adminService.RecordAction(c.Request.Context(), getActor(c), models.AuditActionRunJob, 0, map[string]any{
+		"job":   name,
+		"items": status.LastItems,
+		"error": status.LastError,
+	})
+
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"log"
+	"net/http"
+)
+
+type PresenceHandler struct {
+	presenceService *service.PresenceService
+}
+
+func NewPresenceHandler(presenceService *service.PresenceService) *PresenceHandler {
+	return &PresenceHandler{presenceService: presenceService}
+}
+
+// Heartbeat is sent by clients every heartbeat_interval while they are open.
+func (h *PresenceHandler) Heartbeat(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return
+	}
+
+	var req dto.PresenceHeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	status, err := h.presenceService.Heartbeat(c.Request.Context(), userID, req.ConnectionID, req.Active)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{
+				Error: "user_not_found",
+			})
+			return
+		}
+		log.Printf("[ERROR] Failed to record heartbeat of userID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "internal_error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.PresenceResponse{
+		Status:            status,
+		HeartbeatInterval: int64(h.presenceService.HeartbeatInterval().Seconds()),
+	})
+}
+
+// Disconnect is sent by clients that are closing, so that they do not stay
+// online until their last heartbeat expires.
+func (h *PresenceHandler) Disconnect(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return
+	}
+
+	var req dto.PresenceDisconnectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.presenceService.Disconnect(c.Request.Context(), userID, req.ConnectionID); err != nil {
+		log.Printf("[ERROR] Failed to disconnect userID=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "internal_error",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"net/http"
+)
+
+// QRLoginHandler shares the cookie mode and DPoP settings of the AuthHandler,
+// since the web client that polls for a ticket signs in like any other.
+type QRLoginHandler struct {
+	qrLoginService *service.QRLoginService
+	auth           *AuthHandler
+}
+
+func NewQRLoginHandler(qrLoginService *service.QRLoginService, auth *AuthHandler) *QRLoginHandler {
+	return &QRLoginHandler{qrLoginService: qrLoginService, auth: auth}
+}
+
+func (h *QRLoginHandler) CreateTicket(c *gin.Context) {
+	userAgent, ip := getClientInfo(c)
+	resp, err := h.qrLoginService.CreateTicket(c.Request.Context(), userAgent, ip)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to create login ticket",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Poll holds the request open until the ticket is decided or the poll times
+// out, in which case it answers 202 and the client polls again.
+func (h *QRLoginHandler) Poll(c *gin.Context) {
+	var req dto.QRLoginPollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	dpopKey, ok := requestDPoPKey(c, h.auth.proofs)
+	if !ok {
+		return
+	}
+
+	authResp, err := h.qrLoginService.Poll(c.Request.Context(), &req, dpopKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrQRLoginPending):
+			c.JSON(http.StatusAccepted, gin.H{"status": "pending"})
+		case errors.Is(err, service.ErrQRLoginTicketNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{
+				Error:   "ticket_not_found",
+				Message: "The login ticket is invalid or has expired",
+			})
+		case errors.Is(err, service.ErrQRLoginDenied):
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{
+				Error:   "ticket_denied",
+				Message: "The login was denied on the mobile app",
+			})
+		case errors.Is(err, service.ErrSessionLimitReached):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error:   "session_limit_reached",
+				Message: "Sign out of another device before signing in on this one",
+			})
+		default:
+			if writeSuspendedError(c, err) {
+				return
+			}
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "internal_error",
+			})
+		}
+		return
+	}
+
+	h.auth.writeAuthResponse(c, http.StatusOK, authResp, h.auth.wantsCookies(c))
+}
+
+func (h *QRLoginHandler) GetTicket(c *gin.Context) {
+	info, err := h.qrLoginService.GetTicket(c.Request.Context(), c.Query("ticket_id"))
+	if err != nil {
+		writeTicketError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *QRLoginHandler) Approve(c *gin.Context) {
+	h.decide(c, true)
+}
+
+func (h *QRLoginHandler) Deny(c *gin.Context) {
+	h.decide(c, false)
+}
+
+func (h *QRLoginHandler) decide(c *gin.Context, approve bool) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return
+	}
+
+	var req dto.QRLoginDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := h.qrLoginService.Decide(c.Request.Context(), userID, req.TicketID, approve); err != nil {
+		writeTicketError(c, err)
+		return
+	}
+
+	message := "Login denied"
+	if approve {
+		message = "Login approved"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func writeTicketError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrQRLoginTicketNotFound) {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error:   "ticket_not_found",
+			Message: "The login ticket is invalid, expired or already used",
+		})
+		return
+	}
+	if writeSuspendedError(c, err) {
+		return
+	}
+	c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+		Error: "internal_error",
+	})
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"log"
+	"net/http"
+)
+
+type ServiceClientHandler struct {
+	serviceClientService *service.ServiceClientService
+}
+
+func NewServiceClientHandler(serviceClientService *service.ServiceClientService) *ServiceClientHandler {
+	return &ServiceClientHandler{serviceClientService: serviceClientService}
+}
+
+// Token is the client credentials token endpoint for other services.
+func (h *ServiceClientHandler) Token(c *gin.Context) {
+	var req dto.ClientCredentialsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	clientID, clientSecret, basicAuth := c.Request.BasicAuth()
+	if basicAuth {
+		if req.ClientSecret != "" {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Use either HTTP Basic or client_secret, not both",
+			})
+			return
+		}
+		req.ClientID, req.ClientSecret = clientID, clientSecret
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	resp, err := h.serviceClientService.IssueToken(c.Request.Context(), &req, middleware.ClientCertIdentity(c.Request))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidClient):
+			if basicAuth {
+				c.Header("WWW-Authenticate", `Basic realm="token"`)
+			}
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error:   err.Error(),
+				Message: "Client authentication failed",
+			})
+		case errors.Is(err, service.ErrInvalidScope):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error:   err.Error(),
+				Message: "The requested scope exceeds the scopes of the client",
+			})
+		case errors.Is(err, service.ErrUnsupportedGrantType):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: err.Error(),
+			})
+		default:
+			log.Printf("[ERROR] Failed to issue service token: %v", err)
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "server_error",
+			})
+		}
+		return
+	}
+
+	log.Printf("[INFO] Issued service token to %s with scope %q", req.ClientID, resp.Scope)
+	c.JSON(http.StatusOK, resp)
+}
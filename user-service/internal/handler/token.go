@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// jwksMaxAge bounds how long verifiers may cache the key set. A newly
+// published key is used for signing only after the key service's next
+// reload, so verifiers that refetch on an unknown kid never miss it.
+const jwksMaxAge = 5 * time.Minute
+
+// TokenHandler lets other services verify access tokens, either locally with
+// the published keys or by asking this service.
+type TokenHandler struct {
+	tokenManager   *jwt.TokenManager
+	tokenValidator *service.TokenValidator
+}
+
+func NewTokenHandler(tokenManager *jwt.TokenManager, tokenValidator *service.TokenValidator) *TokenHandler {
+	return &TokenHandler{tokenManager: tokenManager, tokenValidator: tokenValidator}
+}
+
+// JWKS publishes the public keys access tokens are signed with. Tokens
+// signed with the shared HMAC secret cannot be verified with it.
+func (h *TokenHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(jwksMaxAge.Seconds())))
+	c.JSON(http.StatusOK, jwt.NewJWKS(h.tokenManager.VerificationKeys()))
+}
+
+// Introspect is the RFC 7662 introspection endpoint. Only user access tokens
+// that are neither revoked nor belong to a suspended user are active.
+func (h *TokenHandler) Introspect(c *gin.Context) {
+	var req dto.IntrospectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	claims, err := h.tokenValidator.Validate(c.Request.Context(), req.Token)
+	if err != nil {
+		if !errors.Is(err, jwt.ErrInvalidToken) && !errors.Is(err, jwt.ErrExpiredToken) {
+			log.Printf("[INFO] Token introspected by %s is inactive: %v", middleware.GetServiceClientID(c), err)
+		}
+		c.JSON(http.StatusOK, dto.IntrospectionResponse{Active: false})
+		return
+	}
+
+	resp := dto.IntrospectionResponse{
+		Active:    true,
+		Subject:   strconv.FormatInt(claims.UserId, 10),
+		UserID:    claims.UserId,
+		Username:  claims.Username,
+		Email:     claims.Email,
+		SessionID: claims.SessionID,
+		ActorID:   claims.ActorID(),
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+	}
+	if jkt := claims.KeyThumbprint(); jkt != "" {
+		resp.Cnf = &dto.IntrospectionCnf{JKT: jkt}
+		resp.TokenType = "DPoP"
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
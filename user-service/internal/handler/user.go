@@ -7,15 +7,18 @@ import (
 	"github.com/zhanserikAmangeldi/user-service/internal/dto"
 	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
 	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"log"
 	"net/http"
 )
 
 type UserHandler struct {
-	userRepo *repository.UserRepository
+	userRepo        *repository.UserRepository
+	presenceService *service.PresenceService
 }
 
-func NewUserHandler(userRepo *repository.UserRepository) *UserHandler {
-	return &UserHandler{userRepo: userRepo}
+func NewUserHandler(userRepo *repository.UserRepository, presenceService *service.PresenceService) *UserHandler {
+	return &UserHandler{userRepo: userRepo, presenceService: presenceService}
 }
 
 func (h *UserHandler) GetMe(c *gin.Context) {
@@ -43,6 +46,7 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 		return
 	}
 
+	h.presenceService.Resolve(c.Request.Context(), user, true)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -81,8 +85,14 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 	if req.Bio != nil {
 		user.Bio = req.Bio
 	}
-	if req.Status != nil {
-		user.Status = *req.Status
+	overrideChanged := false
+	if req.PresenceOverride != nil {
+		override := req.PresenceOverride
+		if *override == "none" {
+			override = nil
+		}
+		overrideChanged = !equalStrings(override, user.PresenceOverride)
+		user.PresenceOverride = override
 	}
 
 	err = h.userRepo.Update(c.Request.Context(), user)
@@ -93,6 +103,13 @@ func (h *UserHandler) UpdateMe(c *gin.Context) {
 		return
 	}
 
+	if overrideChanged {
+		if err := h.presenceService.Refresh(c.Request.Context(), user.ID); err != nil {
+			log.Printf("[WARN] Failed to refresh presence of userID=%d: %v", user.ID, err)
+		}
+	}
+
+	h.presenceService.Resolve(c.Request.Context(), user, true)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -123,5 +140,13 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
+	h.presenceService.Resolve(c.Request.Context(), user, false)
 	c.JSON(http.StatusOK, user)
 }
+
+func equalStrings(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/middleware"
+	"github.com/zhanserikAmangeldi/user-service/internal/service"
+	"net/http"
+)
+
+type WSTicketHandler struct {
+	wsTicketService *service.WSTicketService
+}
+
+func NewWSTicketHandler(wsTicketService *service.WSTicketService) *WSTicketHandler {
+	return &WSTicketHandler{wsTicketService: wsTicketService}
+}
+
+func (h *WSTicketHandler) Issue(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return
+	}
+
+	resp, err := h.wsTicketService.Issue(c.Request.Context(), userID, middleware.GetSessionID(c), middleware.GetUsername(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error:   "internal_error",
+			Message: "Failed to issue websocket ticket",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Validate is called by the chat gateway on a WebSocket upgrade. It consumes
+// the ticket, so the gateway must call it exactly once per connection. It
+// must sit behind ServiceAuth and refuses callers that are not a service
+// client, so that a ticket cannot be burnt by whoever holds it.
+func (h *WSTicketHandler) Validate(c *gin.Context) {
+	if middleware.GetServiceClientID(c) == "" {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error:   "service_client_required",
+			Message: "Tickets can only be validated by the chat gateway",
+		})
+		return
+	}
+
+	var req dto.ValidateWSTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	validation, err := h.wsTicketService.Validate(c.Request.Context(), req.Ticket)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidWSTicket):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error:   "invalid_ticket",
+				Message: "The ticket is invalid, expired or already used",
+			})
+		case errors.Is(err, service.ErrWSTicketRevoked):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error:   "session_revoked",
+				Message: "The session of the ticket has been revoked",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "internal_error",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, validation)
+}
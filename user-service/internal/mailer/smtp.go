@@ -1,11 +1,16 @@
 package mailer
 
 import (
+	"errors"
 	"fmt"
 	"net/smtp"
 	"time"
 )
 
+// ErrNotConfigured is returned instead of sending when no SMTP credentials
+// are set.
+var ErrNotConfigured = errors.New("smtp credentials are not configured")
+
 type SMTPMailer struct {
 	Host    string
 	Port    int
@@ -16,10 +21,12 @@ type SMTPMailer struct {
 	Render  *TemplateRender
 }
 
-func (m *SMTPMailer) SendVerificationEmail(to, username, token string) error {
-	auth := smtp.PlainAuth("", m.User, m.Pass, m.Host)
-	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+// Configured reports whether credentials are set, so that emails can be sent.
+func (m *SMTPMailer) Configured() bool {
+	return m.User != "" && m.Pass != ""
+}
 
+func (m *SMTPMailer) SendVerificationEmail(to, username, token string) error {
 	link := fmt.Sprintf("%s/verify-email?token=%s", m.BaseURL, token)
 
 	data := map[string]any{
@@ -33,7 +40,32 @@ func (m *SMTPMailer) SendVerificationEmail(to, username, token string) error {
 		return err
 	}
 
-	subject := "Verify your email address"
+	return m.send(to, "Verify your email address", htmlBody)
+}
+
+func (m *SMTPMailer) SendWelcomeEmail(to, username string) error {
+	data := map[string]any{
+		"Username": username,
+		"LoginURL": m.BaseURL,
+		"Year":     time.Now().Year(),
+	}
+
+	htmlBody, err := m.Render.RenderTemplate("welcome.html", data)
+	if err != nil {
+		return err
+	}
+
+	return m.send(to, "Welcome to the new chat application", htmlBody)
+}
+
+func (m *SMTPMailer) send(to, subject, htmlBody string) error {
+	if !m.Configured() {
+		return ErrNotConfigured
+	}
+
+	auth := smtp.PlainAuth("", m.User, m.Pass, m.Host)
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+
 	msg := fmt.Sprintf("Subject: %s\n"+
 		"MIME-version: 1.0;\n"+
 		"Content-Type: text/html; charset=\"UTF-8\";\n%s",
@@ -1,8 +1,10 @@
 package middleware
 
 import (
-	"github.com/redis/go-redis/v9"
+	"errors"
+	"github.com/zhanserikAmangeldi/user-service/pkg/dpop"
 	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
 	"net/http"
 	"strings"
 
@@ -14,9 +16,15 @@ const (
 	userIDKey           = "user_id"
 	usernameKey         = "username"
 	emailKey            = "email"
+	actorIDKey          = "actor_id"
+	sessionIDKey        = "session_id"
 )
 
-func AuthMiddleware(tokenManager *jwt.TokenManager, redisClient *redis.Client) gin.HandlerFunc {
+// AuthMiddleware accepts a user's bearer access token that is validly signed
+// and not revoked; service tokens are rejected. If the revocation store cannot
+// be reached the request is let through, as before. Tokens bound to a DPoP
+// key must come with the DPoP scheme and a proof by that key for this request.
+func AuthMiddleware(tokenManager *jwt.TokenManager, revocations *revocation.Checker, proofs *dpop.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader(authorizationHeader)
 		if authHeader == "" {
@@ -26,7 +34,7 @@ func AuthMiddleware(tokenManager *jwt.TokenManager, redisClient *redis.Client) g
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != dpopScheme) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
 			c.Abort()
 			return
@@ -34,25 +42,68 @@ func AuthMiddleware(tokenManager *jwt.TokenManager, redisClient *redis.Client) g
 
 		token := parts[1]
 
-		ctx := c.Request.Context()
-
-		exists, err := redisClient.Exists(ctx, "revoked:"+token).Result()
-		if err == nil && exists > 0 {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+		claims, err := tokenManager.ValidateAccessToken(token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		claims, err := tokenManager.ValidateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		if claims.IsService() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "service tokens are not accepted here"})
 			c.Abort()
 			return
 		}
 
+		if jkt := claims.KeyThumbprint(); jkt != "" || parts[0] == dpopScheme {
+			if jkt == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "token is not DPoP-bound"})
+				c.Abort()
+				return
+			}
+			if parts[0] != dpopScheme {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "DPoP-bound token requires the DPoP scheme"})
+				c.Abort()
+				return
+			}
+			thumbprint, err := VerifyDPoPProof(c, proofs, token)
+			if err != nil {
+				AbortInvalidDPoP(c, err)
+				return
+			}
+			if thumbprint != jkt {
+				AbortInvalidDPoP(c, errors.New("proof key does not match the token"))
+				return
+			}
+		}
+
+		status, err := revocations.Check(c.Request.Context(), claims.UserId, claims.SessionID, claims.IssuedAt.Time)
+		if err == nil {
+			if status.Suspended {
+				resp := gin.H{"error": "account_suspended", "message": "Your account has been suspended"}
+				if status.SuspendedUntil != nil {
+					resp["ends_at"] = status.SuspendedUntil
+				}
+				c.JSON(http.StatusForbidden, resp)
+				c.Abort()
+				return
+			}
+			if status.Revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Set(userIDKey, claims.UserId)
 		c.Set(usernameKey, claims.Username)
 		c.Set(emailKey, claims.Email)
+		if claims.SessionID != 0 {
+			c.Set(sessionIDKey, claims.SessionID)
+		}
+		if claims.IsImpersonated() {
+			c.Set(actorIDKey, claims.ActorID())
+		}
 
 		c.Next()
 	}
@@ -75,6 +126,25 @@ func GetUsername(c *gin.Context) string {
 	return username.(string)
 }
 
+// GetActorID returns the admin acting on behalf of the user, or 0 for a regular request.
+func GetActorID(c *gin.Context) int64 {
+	actorID, exists := c.Get(actorIDKey)
+	if !exists {
+		return 0
+	}
+	return actorID.(int64)
+}
+
+// GetSessionID returns the session of the access token, or 0 for tokens that
+// do not belong to a session, such as impersonation tokens.
+func GetSessionID(c *gin.Context) int64 {
+	sessionID, exists := c.Get(sessionIDKey)
+	if !exists {
+		return 0
+	}
+	return sessionID.(int64)
+}
+
 func GetEmail(c *gin.Context) string {
 	email, exists := c.Get(emailKey)
 	if !exists {
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/pkg/dpop"
+	"log"
+	"net/http"
+)
+
+const dpopScheme = "DPoP"
+
+// RequestURL reconstructs the URL the client addressed, as a DPoP proof's htu
+// names it, honouring X-Forwarded-Proto set by a TLS-terminating proxy.
+func RequestURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.Path
+}
+
+// VerifyDPoPProof checks the DPoP header of the request and returns the
+// thumbprint of the proof key. accessToken is set when the proof accompanies
+// an access token. If the replay cache is down the proof is accepted, like
+// revocation checks are.
+func VerifyDPoPProof(c *gin.Context, proofs *dpop.Verifier, accessToken string) (string, error) {
+	proof, err := proofs.Verify(c.Request.Context(), c.GetHeader(dpop.HeaderName), c.Request.Method, RequestURL(c), accessToken)
+	if err != nil {
+		if !errors.Is(err, dpop.ErrUnavailable) {
+			return "", err
+		}
+		log.Printf("[WARN] Accepting DPoP proof without replay check: %v", err)
+	}
+	return proof.Thumbprint, nil
+}
+
+// AbortInvalidDPoP responds as RFC 9449 describes for a missing or bad proof.
+func AbortInvalidDPoP(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_dpop_proof", "message": err.Error()})
+	c.Abort()
+}
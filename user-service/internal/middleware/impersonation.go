@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"log"
+	"net/http"
+)
+
+// AuditImpersonation must run after AuthMiddleware. Every request made with an
+// impersonation token is logged and written to the audit log once it completes.
+func AuditImpersonation(auditRepo *repository.AuditLogRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID := GetActorID(c)
+		if actorID == 0 {
+			c.Next()
+			return
+		}
+
+		userID := GetUserID(c)
+		log.Printf("[IMPERSONATION] actorID=%d userID=%d %s %s",
+			actorID, userID, c.Request.Method, c.Request.URL.Path)
+
+		c.Next()
+
+		metadata, _ := json.Marshal(map[string]any{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+			"status": c.Writer.Status(),
+		})
+
+		entry := &models.AuditLog{
+			ActorID:      &actorID,
+			Action:       models.AuditActionImpersonatedRequest,
+			TargetUserID: &userID,
+			Metadata:     metadata,
+		}
+		if userAgent := c.Request.UserAgent(); userAgent != "" {
+			entry.UserAgent = &userAgent
+		}
+		if ip := c.ClientIP(); ip != "" {
+			entry.IPAddress = &ip
+		}
+
+		// The client may already be gone, but the entry must still be written.
+		ctx := context.WithoutCancel(c.Request.Context())
+		if err := auditRepo.Create(ctx, entry); err != nil {
+			log.Printf("[ERROR] Failed to audit impersonated request (actorID=%d, userID=%d): %v",
+				actorID, userID, err)
+		}
+	}
+}
+
+// DenyImpersonation rejects requests made with an impersonation token. It
+// guards routes that change credentials or grant elevated access.
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetActorID(c) != 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "impersonation_forbidden"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/internal/tlsconfig"
+	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const serviceClientIDKey = "service_client_id"
+
+// ServiceAuth guards internal routes called by other services. It accepts
+// only service tokens of clients that are not disabled, and only those
+// granted every one of scopes; user tokens are rejected even when they are
+// valid. A request without an
+// Authorization header is accepted when it came with a verified client
+// certificate registered as the TLS identity of a service client holding
+// the scopes.
+func ServiceAuth(tokenManager *jwt.TokenManager, clients *repository.ServiceClientRepository, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(authorizationHeader) == "" {
+			if identity := ClientCertIdentity(c.Request); identity != "" {
+				authenticateClientCert(c, clients, identity, scopes)
+				return
+			}
+		}
+
+		scheme, token, ok := strings.Cut(c.GetHeader(authorizationHeader), " ")
+		if !ok || scheme != "Bearer" {
+			c.Header("WWW-Authenticate", `Bearer realm="internal"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "service token required"})
+			c.Abort()
+			return
+		}
+
+		claims, err := tokenManager.ValidateAccessToken(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="internal", error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			c.Abort()
+			return
+		}
+
+		if !claims.IsService() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user tokens are not accepted on internal routes"})
+			c.Abort()
+			return
+		}
+
+		client, err := clients.GetByClientIDCached(c.Request.Context(), claims.ClientID)
+		if err != nil {
+			if !errors.Is(err, repository.ErrServiceClientNotFound) {
+				log.Printf("[ERROR] Failed to look up service client %s: %v", claims.ClientID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal_error"})
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": "unknown service client"})
+			c.Abort()
+			return
+		}
+		if client.IsDisabled() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "service client disabled"})
+			c.Abort()
+			return
+		}
+
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				c.Header("WWW-Authenticate", `Bearer realm="internal", error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "scope": scope})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set(serviceClientIDKey, claims.ClientID)
+
+		c.Next()
+	}
+}
+
+func authenticateClientCert(c *gin.Context, clients *repository.ServiceClientRepository, identity string, scopes []string) {
+	client, err := clients.GetByTLSIdentity(c.Request.Context(), identity)
+	if err != nil {
+		if !errors.Is(err, repository.ErrServiceClientNotFound) {
+			log.Printf("[ERROR] Failed to look up service client for %s: %v", identity, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal_error"})
+			c.Abort()
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "unknown client certificate"})
+		c.Abort()
+		return
+	}
+	if client.IsDisabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "service client disabled"})
+		c.Abort()
+		return
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope", "scope": scope})
+			c.Abort()
+			return
+		}
+	}
+
+	c.Set(serviceClientIDKey, client.ClientID)
+
+	c.Next()
+}
+
+// GetServiceClientID returns the service client calling an internal route,
+// or "" for requests by users.
+func GetServiceClientID(c *gin.Context) string {
+	clientID, exists := c.Get(serviceClientIDKey)
+	if !exists {
+		return ""
+	}
+	return clientID.(string)
+}
+
+// ClientCertIdentity returns the identity of the verified TLS client
+// certificate of the request, or "" if there is none.
+func ClientCertIdentity(r *http.Request) string {
+	return tlsconfig.ClientIdentity(r.TLS)
+}
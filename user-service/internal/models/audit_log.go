@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreateUser     = "user.create"
+	AuditActionSetRole        = "user.set_role"
+	AuditActionVerifyEmail    = "user.verify_email"
+	AuditActionResetPassword  = "user.reset_password"
+	AuditActionRevokeSessions = "user.revoke_sessions"
+	AuditActionDeleteUser     = "user.delete"
+	AuditActionRestoreUser    = "user.restore"
+	AuditActionSuspendUser    = "user.suspend"
+	AuditActionLiftSuspension = "user.lift_suspension"
+	AuditActionImpersonate    = "user.impersonate"
+	AuditActionBulkImport     = "user.bulk_import"
+	AuditActionBulkExport     = "user.bulk_export"
+
+	AuditActionImpersonatedRequest = "impersonation.request"
+
+	AuditActionRunJob = "job.run"
+)
+
+type AuditLog struct {
+	ID           int64           `json:"id"`
+	ActorID      *int64          `json:"actor_id,omitempty"`
+	Action       string          `json:"action"`
+	TargetUserID *int64          `json:"target_user_id,omitempty"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+	IPAddress    *string         `json:"ip_address,omitempty"`
+	UserAgent    *string         `json:"user_agent,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+	// DeviceAuthorizationConsumed marks an approved request whose tokens have
+	// been handed out, so that a device code yields one session only.
+	DeviceAuthorizationConsumed = "consumed"
+)
+
+// DeviceAuthorization is an RFC 8628 device authorization request. Only a hash
+// of the device code is stored; the user code is stored without its dash.
+type DeviceAuthorization struct {
+	ID             int64
+	DeviceCodeHash string
+	UserCode       string
+	ClientID       *string
+	UserAgent      *string
+	IPAddress      *string
+	Status         string
+	UserID         *int64
+	Interval       time.Duration
+	LastPolledAt   *time.Time
+	ExpiresAt      time.Time
+	DecidedAt      *time.Time
+	CreatedAt      time.Time
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Scopes that can be granted to service clients.
+const (
+	ScopeUsersRead         = "users:read"
+	ScopeTokensValidate    = "tokens:validate"
+	ScopeWSTicketsValidate = "ws_tickets:validate"
+	ScopeSessionsRevoke    = "sessions:revoke"
+	ScopePresenceWrite     = "presence:write"
+)
+
+var ServiceScopes = []string{ScopeUsersRead, ScopeTokensValidate, ScopeWSTicketsValidate, ScopeSessionsRevoke, ScopePresenceWrite}
+
+// ServiceClient is another service allowed to obtain service tokens with the
+// client credentials grant, authenticating with a secret, a client
+// certificate whose identity matches TLSIdentity, or both.
+type ServiceClient struct {
+	ID          int64      `json:"id"`
+	ClientID    string     `json:"client_id"`
+	Name        string     `json:"name"`
+	SecretHash  *string    `json:"-"`
+	TLSIdentity *string    `json:"tls_identity,omitempty"`
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"`
+}
+
+func (c *ServiceClient) IsDisabled() bool {
+	return c.DisabledAt != nil
+}
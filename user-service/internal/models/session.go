@@ -3,12 +3,21 @@ package models
 import "time"
 
 type SessionInfo struct {
-	ID        int64     `json:"id"`
-	UserAgent *string   `json:"user_agent,omitempty"`
-	IPAddress *string   `json:"ip_address,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
-	IsCurrent bool      `json:"is_current"`
+	ID             int64     `json:"id"`
+	DeviceName     *string   `json:"device_name,omitempty"`
+	DeviceType     string    `json:"device_type"`
+	Browser        *string   `json:"browser,omitempty"`
+	OS             *string   `json:"os,omitempty"`
+	UserAgent      *string   `json:"user_agent,omitempty"`
+	IPAddress      *string   `json:"ip_address,omitempty"`
+	FirstIPAddress *string   `json:"first_ip_address,omitempty"`
+	Location       *string   `json:"location,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastActiveAt   time.Time `json:"last_active_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	// AbsoluteExpiresAt is the latest ExpiresAt can slide to with refreshes.
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	IsCurrent         bool      `json:"is_current"`
 }
 
 type SessionListResponse struct {
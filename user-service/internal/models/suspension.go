@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Suspension struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Reason      string     `json:"reason"`
+	ModeratorID *int64     `json:"moderator_id,omitempty"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"` // nil means the ban is permanent
+	LiftedAt    *time.Time `json:"lifted_at,omitempty"`
+	LiftedBy    *int64     `json:"lifted_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (s *Suspension) IsPermanent() bool {
+	return s.EndsAt == nil
+}
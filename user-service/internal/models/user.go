@@ -2,16 +2,35 @@ package models
 
 import "time"
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Presence overrides a user can set on top of their heartbeat-derived status.
+const (
+	PresenceBusy      = "busy"
+	PresenceInvisible = "invisible"
+)
+
 type User struct {
-	ID           int64      `json:"id"`
-	Username     string     `json:"username"`
-	Email        string     `json:"email"`
-	PasswordHash string     `json:"-"`
-	DisplayName  *string    `json:"display_name,omitempty"`
-	AvatarURL    *string    `json:"avatar_url,omitempty"`
-	Bio          *string    `json:"bio,omitempty"`
-	Status       string     `json:"status"`
-	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID               int64      `json:"id"`
+	Username         string     `json:"username"`
+	Email            string     `json:"email"`
+	PasswordHash     string     `json:"-"`
+	DisplayName      *string    `json:"display_name,omitempty"`
+	AvatarURL        *string    `json:"avatar_url,omitempty"`
+	Bio              *string    `json:"bio,omitempty"`
+	Status           string     `json:"status"`
+	PresenceOverride *string    `json:"presence_override,omitempty"`
+	Role             string     `json:"role"`
+	IsVerified       bool       `json:"is_verified"`
+	LastSeenAt       *time.Time `json:"last_seen_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
@@ -0,0 +1,23 @@
+package presence
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	published = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "presence_updates_published_total",
+		Help: "Presence updates published to Redis.",
+	})
+
+	coalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "presence_updates_coalesced_total",
+		Help: "Presence updates replaced by a newer one before a slow watcher took them.",
+	})
+
+	watchers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "presence_watchers",
+		Help: "Open presence subscriptions on this instance.",
+	})
+)
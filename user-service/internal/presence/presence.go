@@ -0,0 +1,253 @@
+// Package presence tracks users' connections by their heartbeats and fans
+// out changes of their status and last-seen time to watchers. Updates travel
+// through Redis pub/sub, so a watcher on any replica sees changes made on
+// every other replica.
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const channelPrefix = "presence:"
+
+func channel(userID int64) string {
+	return channelPrefix + strconv.FormatInt(userID, 10)
+}
+
+// Update is the presence of a user after a change.
+type Update struct {
+	UserID     int64      `json:"user_id"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// Hub publishes updates and delivers them to local subscriptions. It holds a
+// single Redis subscription shared by all watchers of this replica, and
+// subscribes to a user's channel only while someone watches the user. A nil
+// Hub publishes nothing.
+type Hub struct {
+	client redis.UniversalClient
+	pubsub *redis.PubSub
+
+	// subscribeMu orders changes of the Redis subscription the same way as
+	// the changes of subs that caused them. mu, which delivery takes, is never
+	// held across a call to Redis.
+	subscribeMu sync.Mutex
+
+	mu   sync.Mutex
+	subs map[int64]map[*Subscription]struct{}
+}
+
+func NewHub(client redis.UniversalClient) *Hub {
+	return &Hub{
+		client: client,
+		pubsub: client.Subscribe(context.Background()),
+		subs:   make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+// Publish announces an update to every replica. Errors are logged only:
+// presence is best effort and must not fail the change that caused it.
+func (h *Hub) Publish(ctx context.Context, update Update) {
+	if h == nil {
+		return
+	}
+
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return
+	}
+	if err := h.client.Publish(ctx, channel(update.UserID), payload).Err(); err != nil {
+		log.Printf("[WARN] Failed to publish presence of userID=%d: %v", update.UserID, err)
+		return
+	}
+	published.Inc()
+}
+
+// Run delivers updates received from Redis until ctx is cancelled. The Redis
+// client reconnects and resubscribes by itself after connection failures.
+func (h *Hub) Run(ctx context.Context) {
+	messages := h.pubsub.Channel()
+	defer h.pubsub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var update Update
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+				log.Printf("[WARN] Ignoring malformed presence update on %s: %v", msg.Channel, err)
+				continue
+			}
+			if id, err := strconv.ParseInt(strings.TrimPrefix(msg.Channel, channelPrefix), 10, 64); err == nil {
+				update.UserID = id
+			}
+			h.dispatch(update)
+		}
+	}
+}
+
+func (h *Hub) dispatch(update Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[update.UserID] {
+		sub.push(update)
+	}
+}
+
+// Subscribe starts watching userIDs. Updates published before Redis has
+// processed the subscription are not delivered, so callers that need the
+// current state read it after subscribing. The subscription must be closed.
+func (h *Hub) Subscribe(ctx context.Context, userIDs []int64) (*Subscription, error) {
+	sub := &Subscription{
+		hub:     h,
+		userIDs: make(map[int64]struct{}, len(userIDs)),
+		pending: make(map[int64]Update),
+		notify:  make(chan struct{}, 1),
+	}
+
+	h.subscribeMu.Lock()
+	defer h.subscribeMu.Unlock()
+
+	h.mu.Lock()
+	var channels []string
+	for _, id := range userIDs {
+		if _, dup := sub.userIDs[id]; dup {
+			continue
+		}
+		sub.userIDs[id] = struct{}{}
+
+		if h.subs[id] == nil {
+			h.subs[id] = make(map[*Subscription]struct{})
+			channels = append(channels, channel(id))
+		}
+		h.subs[id][sub] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	if len(channels) > 0 {
+		if err := h.pubsub.Subscribe(ctx, channels...); err != nil {
+			h.mu.Lock()
+			h.remove(sub)
+			h.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	watchers.Inc()
+	return sub, nil
+}
+
+// remove drops sub and returns the channels nobody watches anymore. h.mu must
+// be held.
+func (h *Hub) remove(sub *Subscription) []string {
+	var channels []string
+	for id := range sub.userIDs {
+		delete(h.subs[id], sub)
+		if len(h.subs[id]) == 0 {
+			delete(h.subs, id)
+			channels = append(channels, channel(id))
+		}
+	}
+	return channels
+}
+
+// Subscription is one watcher's view of the hub. A slow consumer never blocks
+// the hub: updates it has not taken yet are coalesced, keeping only the
+// latest update per user, so its backlog is bounded by the number of users it
+// watches.
+type Subscription struct {
+	hub     *Hub
+	userIDs map[int64]struct{}
+
+	mu      sync.Mutex
+	pending map[int64]Update
+	order   []int64
+	closed  bool
+	notify  chan struct{}
+}
+
+func (s *Subscription) push(update Update) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	if _, queued := s.pending[update.UserID]; queued {
+		coalesced.Inc()
+	} else {
+		s.order = append(s.order, update.UserID)
+	}
+	s.pending[update.UserID] = update
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Next waits for updates and returns those received since the last call, in
+// the order the users first changed.
+func (s *Subscription) Next(ctx context.Context) ([]Update, error) {
+	for {
+		s.mu.Lock()
+		if len(s.order) > 0 {
+			updates := make([]Update, 0, len(s.order))
+			for _, id := range s.order {
+				updates = append(updates, s.pending[id])
+				delete(s.pending, id)
+			}
+			s.order = s.order[:0]
+			s.mu.Unlock()
+			return updates, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.notify:
+		}
+	}
+}
+
+// Close stops the subscription and unsubscribes from users nobody else on
+// this replica watches.
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	h := s.hub
+	h.subscribeMu.Lock()
+	defer h.subscribeMu.Unlock()
+
+	h.mu.Lock()
+	channels := h.remove(s)
+	h.mu.Unlock()
+
+	if len(channels) > 0 {
+		if err := h.pubsub.Unsubscribe(context.Background(), channels...); err != nil {
+			log.Printf("[WARN] Failed to unsubscribe from presence channels: %v", err)
+		}
+	}
+
+	watchers.Dec()
+}
@@ -0,0 +1,221 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// Presence statuses. StatusInvisible is only ever shown to the user
+// themselves; everyone else sees an invisible user as offline.
+const (
+	StatusOnline    = "online"
+	StatusAway      = "away"
+	StatusBusy      = "busy"
+	StatusOffline   = "offline"
+	StatusInvisible = "invisible"
+)
+
+const (
+	// dueKey is a sorted set of user IDs scored by the unix milliseconds at
+	// which their status may change next without a heartbeat.
+	dueKey = "presence:due"
+	// activityTTL keeps the last activity time until it has been written
+	// back, even if the sweep falls behind.
+	activityTTL = 7 * 24 * time.Hour
+	statusTTL   = 24 * time.Hour
+)
+
+// connectionsKey is a sorted set of the user's connection IDs scored by the
+// unix milliseconds at which each expires without another heartbeat.
+func connectionsKey(userID int64) string {
+	return "presence:conns:" + strconv.FormatInt(userID, 10)
+}
+
+// activityKey holds the unix milliseconds of the user's last activity.
+func activityKey(userID int64) string {
+	return "presence:active:" + strconv.FormatInt(userID, 10)
+}
+
+// statusKey holds the status last announced for the user, so that replicas
+// announce each change once.
+func statusKey(userID int64) string {
+	return "presence:status:" + strconv.FormatInt(userID, 10)
+}
+
+// State is what the heartbeats say about a user.
+type State struct {
+	Connected bool
+	// ConnectedUntil is when the last connection expires without a heartbeat.
+	ConnectedUntil time.Time
+	LastActiveAt   *time.Time
+}
+
+// Tracker keeps the heartbeats of users' connections in Redis. A connection
+// is up for ttl after its last heartbeat; a connected user is away once
+// their last activity is awayAfter old.
+type Tracker struct {
+	client    redis.UniversalClient
+	ttl       time.Duration
+	awayAfter time.Duration
+}
+
+func NewTracker(client redis.UniversalClient, ttl, awayAfter time.Duration) *Tracker {
+	return &Tracker{client: client, ttl: ttl, awayAfter: awayAfter}
+}
+
+// TTL is how long a connection stays up without a heartbeat.
+func (t *Tracker) TTL() time.Duration {
+	return t.ttl
+}
+
+// Heartbeat keeps connectionID of the user up for another ttl. active
+// reports that the user did something since the last heartbeat, as opposed
+// to an idle client merely staying connected.
+func (t *Tracker) Heartbeat(ctx context.Context, userID int64, connectionID string, active bool) (*State, error) {
+	now := time.Now()
+
+	_, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, connectionsKey(userID), redis.Z{Score: float64(now.Add(t.ttl).UnixMilli()), Member: connectionID})
+		pipe.PExpire(ctx, connectionsKey(userID), t.ttl)
+		if active {
+			pipe.Set(ctx, activityKey(userID), now.UnixMilli(), activityTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t.State(ctx, userID)
+}
+
+// Disconnect takes connectionID of the user down before it expires.
+func (t *Tracker) Disconnect(ctx context.Context, userID int64, connectionID string) error {
+	return t.client.ZRem(ctx, connectionsKey(userID), connectionID).Err()
+}
+
+// Touch records activity of the user without a connection, such as a login.
+func (t *Tracker) Touch(ctx context.Context, userID int64) error {
+	now := time.Now()
+	_, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, activityKey(userID), now.UnixMilli(), activityTTL)
+		pipe.ZAdd(ctx, dueKey, redis.Z{Score: float64(now.UnixMilli()), Member: userID})
+		return nil
+	})
+	return err
+}
+
+// State drops the user's expired connections and returns what is left.
+func (t *Tracker) State(ctx context.Context, userID int64) (*State, error) {
+	now := time.Now()
+
+	var last *redis.ZSliceCmd
+	var activity *redis.StringCmd
+	_, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, connectionsKey(userID), "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		last = pipe.ZRangeWithScores(ctx, connectionsKey(userID), -1, -1)
+		activity = pipe.Get(ctx, activityKey(userID))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	state := &State{}
+	if conns := last.Val(); len(conns) > 0 {
+		state.Connected = true
+		state.ConnectedUntil = time.UnixMilli(int64(conns[0].Score))
+	}
+	if ms, err := activity.Int64(); err == nil {
+		activeAt := time.UnixMilli(ms)
+		state.LastActiveAt = &activeAt
+	}
+	return state, nil
+}
+
+// Status resolves state and the user's override into the status others see.
+func (t *Tracker) Status(state *State, override *string) string {
+	if !state.Connected {
+		return StatusOffline
+	}
+	if override != nil {
+		switch *override {
+		case StatusInvisible:
+			return StatusOffline
+		case StatusBusy:
+			return StatusBusy
+		}
+	}
+	if state.LastActiveAt == nil || time.Since(*state.LastActiveAt) >= t.awayAfter {
+		return StatusAway
+	}
+	return StatusOnline
+}
+
+// NextChange returns when the status resolved from state may change without
+// a heartbeat, or the zero time if it cannot.
+func (t *Tracker) NextChange(state *State) time.Time {
+	if !state.Connected {
+		return time.Time{}
+	}
+	next := state.ConnectedUntil
+	if state.LastActiveAt != nil {
+		if awayAt := state.LastActiveAt.Add(t.awayAfter); awayAt.After(time.Now()) && awayAt.Before(next) {
+			next = awayAt
+		}
+	}
+	return next
+}
+
+// Schedule makes the user due at at, or drops them from the schedule when at
+// is zero.
+func (t *Tracker) Schedule(ctx context.Context, userID int64, at time.Time) error {
+	if at.IsZero() {
+		return t.client.ZRem(ctx, dueKey, userID).Err()
+	}
+	return t.client.ZAdd(ctx, dueKey, redis.Z{Score: float64(at.UnixMilli()), Member: userID}).Err()
+}
+
+// Due returns up to limit users whose status may have changed by now.
+func (t *Tracker) Due(ctx context.Context, limit int64) ([]int64, error) {
+	members, err := t.client.ZRangeByScore(ctx, dueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int64, 0, len(members))
+	for _, member := range members {
+		if id, err := strconv.ParseInt(member, 10, 64); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	return userIDs, nil
+}
+
+// Claim takes the user off the schedule and reports whether this caller did
+// so, so that replicas sweeping at the same time handle each user once.
+func (t *Tracker) Claim(ctx context.Context, userID int64) (bool, error) {
+	removed, err := t.client.ZRem(ctx, dueKey, userID).Result()
+	return removed == 1, err
+}
+
+// SwapStatus records status as announced for the user and reports whether it
+// differs from the previous announcement. A user going offline who was never
+// announced has not changed.
+func (t *Tracker) SwapStatus(ctx context.Context, userID int64, status string) (bool, error) {
+	previous, err := t.client.SetArgs(ctx, statusKey(userID), status, redis.SetArgs{Get: true, TTL: statusTTL}).Result()
+	if errors.Is(err, redis.Nil) {
+		return status != StatusOffline, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return previous != status, nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"strings"
+)
+
+// AuditLogFilter narrows down List results. Zero values mean "no filter".
+type AuditLogFilter struct {
+	ActorID      int64
+	TargetUserID int64
+	Action       string
+	AfterID      int64
+	Limit        int
+}
+
+type AuditLogRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditLogRepository(db *pgxpool.Pool) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+func (r *AuditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	query := `
+		INSERT INTO audit_logs (actor_id, action, target_user_id, metadata, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	var metadata any
+	if len(entry.Metadata) > 0 {
+		metadata = entry.Metadata
+	}
+
+	return r.db.QueryRow(ctx, query,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetUserID,
+		metadata,
+		entry.IPAddress,
+		entry.UserAgent,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// List returns audit entries ordered from newest to oldest, starting after filter.AfterID.
+func (r *AuditLogRepository) List(ctx context.Context, filter AuditLogFilter) ([]*models.AuditLog, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.ActorID > 0 {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetUserID > 0 {
+		addCondition("target_user_id = $%d", filter.TargetUserID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `
+		SELECT id, actor_id, action, target_user_id, metadata, ip_address::text, user_agent, created_at
+		FROM audit_logs
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditLog
+	for rows.Next() {
+		entry := &models.AuditLog{}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.TargetUserID,
+			&entry.Metadata,
+			&entry.IPAddress,
+			&entry.UserAgent,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"time"
+)
+
+var (
+	ErrDeviceAuthorizationNotFound = errors.New("device authorization not found")
+	ErrUserCodeTaken               = errors.New("user code already in use")
+)
+
+// slowDownStep is how much the polling interval grows after a slow_down
+// response, as RFC 8628 section 3.5 requires.
+const slowDownStep = 5 * time.Second
+
+const deviceAuthorizationColumns = `id, device_code_hash, user_code, client_id, user_agent, ip_address::text,
+		       status, user_id, interval_seconds, last_polled_at, expires_at, decided_at, created_at`
+
+type DeviceAuthorizationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDeviceAuthorizationRepository(db *pgxpool.Pool) *DeviceAuthorizationRepository {
+	return &DeviceAuthorizationRepository{db: db}
+}
+
+func scanDeviceAuthorization(row pgx.Row, extra ...any) (*models.DeviceAuthorization, error) {
+	var intervalSeconds int64
+	da := &models.DeviceAuthorization{}
+	dest := []any{
+		&da.ID,
+		&da.DeviceCodeHash,
+		&da.UserCode,
+		&da.ClientID,
+		&da.UserAgent,
+		&da.IPAddress,
+		&da.Status,
+		&da.UserID,
+		&intervalSeconds,
+		&da.LastPolledAt,
+		&da.ExpiresAt,
+		&da.DecidedAt,
+		&da.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDeviceAuthorizationNotFound
+		}
+		return nil, err
+	}
+	da.Interval = time.Duration(intervalSeconds) * time.Second
+	return da, nil
+}
+
+// Create stores a new pending request. It fails with ErrUserCodeTaken if the
+// user code collides with another request, so that the caller can pick a new one.
+func (r *DeviceAuthorizationRepository) Create(ctx context.Context, da *models.DeviceAuthorization) error {
+	query := `
+		INSERT INTO device_authorizations (device_code_hash, user_code, client_id, user_agent, ip_address,
+		                                   interval_seconds, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		da.DeviceCodeHash,
+		da.UserCode,
+		da.ClientID,
+		da.UserAgent,
+		da.IPAddress,
+		int64(da.Interval/time.Second),
+		da.ExpiresAt,
+	).Scan(&da.ID, &da.Status, &da.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "device_authorizations_user_code_key" {
+			return ErrUserCodeTaken
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetPendingByUserCode returns the request a user is about to approve or deny.
+func (r *DeviceAuthorizationRepository) GetPendingByUserCode(ctx context.Context, userCode string) (*models.DeviceAuthorization, error) {
+	query := `
+		SELECT ` + deviceAuthorizationColumns + `
+		FROM device_authorizations
+		WHERE user_code = $1 AND status = 'pending' AND expires_at > NOW()
+	`
+
+	return scanDeviceAuthorization(r.db.QueryRow(ctx, query, userCode))
+}
+
+// Decide approves or denies a pending request on behalf of userID.
+func (r *DeviceAuthorizationRepository) Decide(ctx context.Context, userCode string, userID int64, approve bool) (*models.DeviceAuthorization, error) {
+	status := models.DeviceAuthorizationDenied
+	if approve {
+		status = models.DeviceAuthorizationApproved
+	}
+
+	query := `
+		UPDATE device_authorizations
+		SET status = $3, user_id = $2, decided_at = CURRENT_TIMESTAMP
+		WHERE user_code = $1 AND status = 'pending' AND expires_at > NOW()
+		RETURNING ` + deviceAuthorizationColumns
+
+	return scanDeviceAuthorization(r.db.QueryRow(ctx, query, userCode, userID, status))
+}
+
+// Poll records a token request for the device code and reports whether it came
+// sooner than the polling interval allows, in which case the interval is
+// raised by five seconds for all later polls.
+func (r *DeviceAuthorizationRepository) Poll(ctx context.Context, deviceCodeHash string) (*models.DeviceAuthorization, bool, error) {
+	query := `
+		UPDATE device_authorizations d
+		SET last_polled_at = CURRENT_TIMESTAMP,
+		    interval_seconds = CASE WHEN prev.too_fast THEN d.interval_seconds + $2 ELSE d.interval_seconds END
+		FROM (
+			SELECT id, COALESCE(last_polled_at > CURRENT_TIMESTAMP - make_interval(secs => interval_seconds), FALSE) AS too_fast
+			FROM device_authorizations
+			WHERE device_code_hash = $1
+			FOR UPDATE
+		) prev
+		WHERE d.id = prev.id
+		RETURNING d.id, d.device_code_hash, d.user_code, d.client_id, d.user_agent, d.ip_address::text,
+		          d.status, d.user_id, d.interval_seconds, d.last_polled_at, d.expires_at, d.decided_at, d.created_at,
+		          prev.too_fast
+	`
+
+	var tooFast bool
+	da, err := scanDeviceAuthorization(r.db.QueryRow(ctx, query, deviceCodeHash, int64(slowDownStep/time.Second)), &tooFast)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return da, tooFast, nil
+}
+
+// Consume marks an approved request as used. Only one of several concurrent
+// polls succeeds; the others get ErrDeviceAuthorizationNotFound.
+func (r *DeviceAuthorizationRepository) Consume(ctx context.Context, id int64) error {
+	query := `
+		UPDATE device_authorizations
+		SET status = 'consumed'
+		WHERE id = $1 AND status = 'approved'
+	`
+
+	result, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrDeviceAuthorizationNotFound
+	}
+
+	return nil
+}
+
+// Release returns a consumed request to approved, for when no session could be
+// started with it, so that the device can poll again.
+func (r *DeviceAuthorizationRepository) Release(ctx context.Context, id int64) error {
+	query := `
+		UPDATE device_authorizations
+		SET status = 'approved'
+		WHERE id = $1 AND status = 'consumed'
+	`
+
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// DeleteExpired removes requests that expired more than retention ago.
+func (r *DeviceAuthorizationRepository) DeleteExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+		DELETE FROM device_authorizations
+		WHERE expires_at < $1
+	`
+
+	result, err := r.db.Exec(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
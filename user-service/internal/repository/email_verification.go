@@ -59,3 +59,16 @@ func (r *EmailVerificationRepository) MarkVerified(ctx context.Context, id int64
 	_, err := r.db.Exec(ctx, query, id)
 	return err
 }
+
+// DeleteExpired removes verification tokens that expired more than retention ago.
+func (r *EmailVerificationRepository) DeleteExpired(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+		DELETE FROM email_verifications
+		WHERE expires_at < $1
+	`
+	result, err := r.db.Exec(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zhanserikAmangeldi/user-service/internal/cache"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+)
+
+var (
+	ErrServiceClientNotFound = errors.New("service client not found")
+	ErrServiceClientExists   = errors.New("service client already exists")
+)
+
+const serviceClientColumns = `id, client_id, name, secret_hash, tls_identity, scopes, created_at, disabled_at`
+
+type ServiceClientRepository struct {
+	db    *pgxpool.Pool
+	cache *cache.Cache
+}
+
+// NewServiceClientRepository returns a repository whose GetByClientIDCached
+// reads through clients, invalidated by every write below. clients may be nil.
+func NewServiceClientRepository(db *pgxpool.Pool, clients *cache.Cache) *ServiceClientRepository {
+	return &ServiceClientRepository{db: db, cache: clients}
+}
+
+func serviceClientCacheKey(clientID string) string {
+	return "client:" + clientID
+}
+
+func scanServiceClient(row pgx.Row) (*models.ServiceClient, error) {
+	client := &models.ServiceClient{}
+	err := row.Scan(
+		&client.ID,
+		&client.ClientID,
+		&client.Name,
+		&client.SecretHash,
+		&client.TLSIdentity,
+		&client.Scopes,
+		&client.CreatedAt,
+		&client.DisabledAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrServiceClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+func (r *ServiceClientRepository) Create(ctx context.Context, client *models.ServiceClient) error {
+	query := `
+		INSERT INTO service_clients (client_id, name, secret_hash, tls_identity, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRow(ctx, query,
+		client.ClientID,
+		client.Name,
+		client.SecretHash,
+		client.TLSIdentity,
+		client.Scopes,
+	).Scan(&client.ID, &client.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrServiceClientExists
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *ServiceClientRepository) GetByClientID(ctx context.Context, clientID string) (*models.ServiceClient, error) {
+	query := `
+		SELECT ` + serviceClientColumns + `
+		FROM service_clients
+		WHERE client_id = $1
+	`
+
+	return scanServiceClient(r.db.QueryRow(ctx, query, clientID))
+}
+
+// GetByClientIDCached is GetByClientID for the checks made on every request
+// with a service token. The client may come from the cache, and then has no
+// secret hash, so it must not be used to check a secret.
+func (r *ServiceClientRepository) GetByClientIDCached(ctx context.Context, clientID string) (*models.ServiceClient, error) {
+	client := &models.ServiceClient{}
+	if r.cache.Get(ctx, serviceClientCacheKey(clientID), client) {
+		return client, nil
+	}
+
+	client, err := r.GetByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.Set(ctx, serviceClientCacheKey(clientID), client, 0)
+	return client, nil
+}
+
+func (r *ServiceClientRepository) GetByTLSIdentity(ctx context.Context, identity string) (*models.ServiceClient, error) {
+	query := `
+		SELECT ` + serviceClientColumns + `
+		FROM service_clients
+		WHERE tls_identity = $1
+	`
+
+	return scanServiceClient(r.db.QueryRow(ctx, query, identity))
+}
+
+func (r *ServiceClientRepository) List(ctx context.Context) ([]*models.ServiceClient, error) {
+	query := `
+		SELECT ` + serviceClientColumns + `
+		FROM service_clients
+		ORDER BY client_id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []*models.ServiceClient
+	for rows.Next() {
+		client, err := scanServiceClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+func (r *ServiceClientRepository) SetSecretHash(ctx context.Context, clientID, secretHash string) error {
+	query := `
+		UPDATE service_clients
+		SET secret_hash = $2
+		WHERE client_id = $1 AND disabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, clientID, secretHash)
+	if err != nil {
+		return err
+	}
+	r.cache.Delete(ctx, serviceClientCacheKey(clientID))
+
+	if result.RowsAffected() == 0 {
+		return ErrServiceClientNotFound
+	}
+
+	return nil
+}
+
+func (r *ServiceClientRepository) Disable(ctx context.Context, clientID string) error {
+	query := `
+		UPDATE service_clients
+		SET disabled_at = CURRENT_TIMESTAMP
+		WHERE client_id = $1 AND disabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, clientID)
+	if err != nil {
+		return err
+	}
+	r.cache.Delete(ctx, serviceClientCacheKey(clientID))
+
+	if result.RowsAffected() == 0 {
+		return ErrServiceClientNotFound
+	}
+
+	return nil
+}
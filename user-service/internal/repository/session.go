@@ -2,101 +2,350 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zhanserikAmangeldi/user-service/internal/cache"
 	"time"
 )
 
 var ErrSessionNotFound = errors.New("session not found")
 var ErrSessionExpired = errors.New("session expired")
 var ErrSessionRevoked = errors.New("session revoked")
+var ErrSessionIdle = errors.New("session expired due to inactivity")
+var ErrSessionLimitReached = errors.New("too many active sessions")
 
-type Session struct {
-	ID           int64
-	UserID       int64
-	RefreshToken string
-	AccessToken  string
-	UserAgent    *string
-	IPAddress    *string
-	ExpiresAt    time.Time
-	CreatedAt    time.Time
-	RevokedAt    *time.Time
-}
+// EvictionPolicy decides what happens when a user signs in on one device more
+// than SessionLimit.Max allows.
+type EvictionPolicy string
 
-type SessionRepository struct {
-	db *pgxpool.Pool
+const (
+	EvictionReject      EvictionPolicy = "reject"
+	EvictionOldest      EvictionPolicy = "evict_oldest"
+	EvictionLeastRecent EvictionPolicy = "evict_lru"
+)
+
+func (p EvictionPolicy) Valid() bool {
+	switch p {
+	case EvictionReject, EvictionOldest, EvictionLeastRecent:
+		return true
+	}
+	return false
 }
 
-func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
-	return &SessionRepository{db: db}
+// SessionLimit caps the number of active sessions of a user. A zero Max means
+// no limit.
+type SessionLimit struct {
+	Max      int
+	Eviction EvictionPolicy
 }
 
-func (r *SessionRepository) Create(ctx context.Context, session *Session) error {
-	query := `
-		INSERT INTO sessions (user_id, refresh_token, access_token, user_agent, ip_address, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at
-	`
+// Session is one signed-in device. IPAddress is the address the session was
+// created from and LastIPAddress the one it was last refreshed from.
+//
+// ExpiresAt is the idle deadline: every refresh moves it IdleTimeout past the
+// refresh, but never beyond AbsoluteExpiresAt. A zero IdleTimeout disables the
+// idle window and the session simply lives until AbsoluteExpiresAt.
+type Session struct {
+	ID                int64
+	UserID            int64
+	RefreshToken      string
+	AccessToken       string
+	UserAgent         *string
+	IPAddress         *string
+	Browser           *string
+	OS                *string
+	DeviceType        *string
+	DeviceName        *string
+	LastIPAddress     *string
+	City              *string
+	CountryCode       *string
+	ExpiresAt         time.Time
+	AbsoluteExpiresAt time.Time
+	IdleTimeout       time.Duration
+	CreatedAt         time.Time
+	LastActiveAt      time.Time
+	RevokedAt         *time.Time
+}
 
-	err := r.db.QueryRow(ctx, query,
-		session.UserID,
-		session.RefreshToken,
-		session.AccessToken,
-		session.UserAgent,
-		session.IPAddress,
-		session.ExpiresAt,
-	).Scan(&session.ID, &session.CreatedAt)
+const sessionColumns = `id, user_id, refresh_token, access_token, user_agent, ip_address::text,
+		       browser, os, device_type, device_name, last_ip_address::text,
+		       city, country_code, expires_at, absolute_expires_at, COALESCE(idle_timeout_seconds, 0),
+		       created_at, COALESCE(last_active_at, created_at), revoked_at`
 
-	return err
+// SlidingExpiry returns the idle deadline of the session if it is refreshed at now.
+func (s *Session) SlidingExpiry(now time.Time) time.Time {
+	if s.IdleTimeout <= 0 {
+		return s.AbsoluteExpiresAt
+	}
+	if deadline := now.Add(s.IdleTimeout); deadline.Before(s.AbsoluteExpiresAt) {
+		return deadline
+	}
+	return s.AbsoluteExpiresAt
 }
 
-func (r *SessionRepository) GetByRefreshToken(ctx context.Context, refreshToken string) (*Session, error) {
-	query := `
-		SELECT id, user_id, refresh_token, access_token, user_agent, ip_address::text, 
-		       expires_at, created_at, revoked_at
-		FROM sessions
-		WHERE refresh_token = $1
-	`
-
+func scanSession(row pgx.Row) (*Session, error) {
+	var idleSeconds int64
 	session := &Session{}
-	err := r.db.QueryRow(ctx, query, refreshToken).Scan(
+	err := row.Scan(
 		&session.ID,
 		&session.UserID,
 		&session.RefreshToken,
 		&session.AccessToken,
 		&session.UserAgent,
 		&session.IPAddress,
+		&session.Browser,
+		&session.OS,
+		&session.DeviceType,
+		&session.DeviceName,
+		&session.LastIPAddress,
+		&session.City,
+		&session.CountryCode,
 		&session.ExpiresAt,
+		&session.AbsoluteExpiresAt,
+		&idleSeconds,
 		&session.CreatedAt,
+		&session.LastActiveAt,
 		&session.RevokedAt,
 	)
-
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrSessionNotFound
 		}
 		return nil, err
 	}
+	session.IdleTimeout = time.Duration(idleSeconds) * time.Second
+	return session, nil
+}
+
+type SessionRepository struct {
+	db    *pgxpool.Pool
+	cache *cache.Cache
+}
+
+// NewSessionRepository returns a repository that serves GetByRefreshToken from
+// sessions, a read-through cache keyed by a hash of the refresh token. Writes
+// invalidate it, and since every write re-checks the row in the database a stale
+// entry can at worst let a lookup succeed that the following Rotate rejects.
+// sessions may be nil.
+func NewSessionRepository(db *pgxpool.Pool, sessions *cache.Cache) *SessionRepository {
+	return &SessionRepository{db: db, cache: sessions}
+}
+
+func sessionCacheKey(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *SessionRepository) invalidate(ctx context.Context, refreshTokens ...string) {
+	keys := make([]string, len(refreshTokens))
+	for i, token := range refreshTokens {
+		keys[i] = sessionCacheKey(token)
+	}
+	r.cache.Delete(ctx, keys...)
+}
+
+// NextID reserves an ID for a session that is about to be created, so that the
+// tokens can name their session before the row exists.
+func (r *SessionRepository) NextID(ctx context.Context) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `SELECT nextval(pg_get_serial_sequence('sessions', 'id'))`).Scan(&id)
+	return id, err
+}
+
+// Create stores a new session while keeping the user within limit. The check and
+// the eviction run in one transaction that locks the user row, so concurrent
+// sign-ins cannot overshoot the limit. It returns the sessions evicted to make
+// room, or ErrSessionLimitReached under EvictionReject.
+func (r *SessionRepository) Create(ctx context.Context, session *Session, limit SessionLimit) ([]*Session, error) {
+	var evicted []*Session
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		evicted = nil
+
+		if limit.Max > 0 {
+			_, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR NO KEY UPDATE`, session.UserID)
+			if err != nil {
+				return err
+			}
+
+			var active int
+			err = tx.QueryRow(ctx, `
+				SELECT COUNT(*)
+				FROM sessions
+				WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+			`, session.UserID).Scan(&active)
+			if err != nil {
+				return err
+			}
+
+			if excess := active - limit.Max + 1; excess > 0 {
+				evicted, err = evictSessions(ctx, tx, session.UserID, excess, limit.Eviction)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		query := `
+			INSERT INTO sessions (id, user_id, refresh_token, access_token, user_agent, ip_address,
+			                      browser, os, device_type, device_name, last_ip_address,
+			                      city, country_code, expires_at, absolute_expires_at, idle_timeout_seconds)
+			VALUES (COALESCE(NULLIF($15, 0), nextval(pg_get_serial_sequence('sessions', 'id'))),
+			        $1, $2, $3, $4, $5, $6, $7, $8, $9, $5, $10, $11, $12, $13, NULLIF($14, 0))
+			RETURNING id, created_at, last_active_at
+		`
+
+		return tx.QueryRow(ctx, query,
+			session.UserID,
+			session.RefreshToken,
+			session.AccessToken,
+			session.UserAgent,
+			session.IPAddress,
+			session.Browser,
+			session.OS,
+			session.DeviceType,
+			session.DeviceName,
+			session.City,
+			session.CountryCode,
+			session.ExpiresAt,
+			session.AbsoluteExpiresAt,
+			int64(session.IdleTimeout/time.Second),
+			session.ID,
+		).Scan(&session.ID, &session.CreatedAt, &session.LastActiveAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(evicted) > 0 {
+		tokens := make([]string, len(evicted))
+		for i, e := range evicted {
+			tokens[i] = e.RefreshToken
+		}
+		r.invalidate(ctx, tokens...)
+	}
+
+	session.LastIPAddress = session.IPAddress
+	return evicted, nil
+}
+
+func evictSessions(ctx context.Context, tx pgx.Tx, userID int64, count int, policy EvictionPolicy) ([]*Session, error) {
+	var order string
+	switch policy {
+	case EvictionOldest:
+		order = "created_at ASC"
+	case EvictionLeastRecent:
+		order = "COALESCE(last_active_at, created_at) ASC"
+	default:
+		return nil, ErrSessionLimitReached
+	}
+
+	query := `
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id
+			FROM sessions
+			WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+			ORDER BY ` + order + `, id ASC
+			LIMIT $2
+		)
+		RETURNING ` + sessionColumns
+
+	rows, err := tx.Query(ctx, query, userID, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var evicted []*Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		evicted = append(evicted, session)
+	}
+
+	return evicted, rows.Err()
+}
+
+func (r *SessionRepository) GetByRefreshToken(ctx context.Context, refreshToken string) (*Session, error) {
+	session := &Session{}
+	if !r.cache.Get(ctx, sessionCacheKey(refreshToken), session) {
+		query := `
+			SELECT ` + sessionColumns + `
+			FROM sessions
+			WHERE refresh_token = $1
+		`
+
+		var err error
+		session, err = scanSession(r.db.QueryRow(ctx, query, refreshToken))
+		if err != nil {
+			return nil, err
+		}
+
+		if session.RevokedAt == nil {
+			r.cache.Set(ctx, sessionCacheKey(refreshToken), session, time.Until(session.ExpiresAt))
+		}
+	}
 
 	if session.RevokedAt != nil {
 		return nil, ErrSessionRevoked
 	}
 
 	if time.Now().After(session.ExpiresAt) {
+		if session.ExpiresAt.Before(session.AbsoluteExpiresAt) {
+			return nil, ErrSessionIdle
+		}
 		return nil, ErrSessionExpired
 	}
 
 	return session, nil
 }
 
+// Rotate replaces the tokens of the session holding oldRefreshToken in place, so
+// that the session keeps its ID across refreshes, records the activity and moves
+// the idle deadline to session.ExpiresAt, capped at the absolute expiry. It
+// fails with ErrSessionNotFound if the token was already rotated or revoked, or
+// if the session went idle in the meantime.
+func (r *SessionRepository) Rotate(ctx context.Context, oldRefreshToken string, session *Session) error {
+	query := `
+		UPDATE sessions
+		SET refresh_token = $2,
+		    access_token = $3,
+		    expires_at = LEAST($4, absolute_expires_at),
+		    last_ip_address = COALESCE($5::inet, last_ip_address),
+		    last_active_at = CURRENT_TIMESTAMP
+		WHERE refresh_token = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + sessionColumns + `
+	`
+
+	rotated, err := scanSession(r.db.QueryRow(ctx, query,
+		oldRefreshToken,
+		session.RefreshToken,
+		session.AccessToken,
+		session.ExpiresAt,
+		session.LastIPAddress,
+	))
+	if err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, oldRefreshToken)
+	*session = *rotated
+	return nil
+}
+
 func (r *SessionRepository) GetAllByUserID(ctx context.Context, userID int64) ([]*Session, error) {
 	query := `
-		SELECT id, user_id, refresh_token, access_token, user_agent, ip_address::text,
-		       expires_at, created_at, revoked_at
+		SELECT ` + sessionColumns + `
 		FROM sessions
 		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
-		ORDER BY created_at DESC
+		ORDER BY last_active_at DESC NULLS LAST, created_at DESC
 	`
 
 	rows, err := r.db.Query(ctx, query, userID)
@@ -107,19 +356,7 @@ func (r *SessionRepository) GetAllByUserID(ctx context.Context, userID int64) ([
 
 	var sessions []*Session
 	for rows.Next() {
-		session := &Session{}
-		err := rows.Scan(
-			&session.ID,
-			&session.UserID,
-			&session.RefreshToken,
-			&session.AccessToken,
-			&session.UserAgent,
-			&session.IPAddress,
-			&session.ExpiresAt,
-			&session.CreatedAt,
-			&session.RevokedAt,
-		)
-
+		session, err := scanSession(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -130,23 +367,94 @@ func (r *SessionRepository) GetAllByUserID(ctx context.Context, userID int64) ([
 	return sessions, nil
 }
 
-func (r *SessionRepository) Revoke(ctx context.Context, refreshToken string) error {
+// Revoke revokes the session holding refreshToken and returns it.
+func (r *SessionRepository) Revoke(ctx context.Context, refreshToken string) (*Session, error) {
 	query := `
 		UPDATE sessions
 		SET revoked_at = CURRENT_TIMESTAMP
 		WHERE refresh_token = $1 AND revoked_at IS NULL
+		RETURNING ` + sessionColumns + `
 	`
 
-	result, err := r.db.Exec(ctx, query, refreshToken)
+	session, err := scanSession(r.db.QueryRow(ctx, query, refreshToken))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if result.RowsAffected() == 0 {
-		return ErrSessionNotFound
+	r.invalidate(ctx, refreshToken)
+	return session, nil
+}
+
+// RevokeByID revokes an active session that belongs to userID and returns it.
+func (r *SessionRepository) RevokeByID(ctx context.Context, userID, sessionID int64) (*Session, error) {
+	query := `
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + sessionColumns + `
+	`
+
+	session, err := scanSession(r.db.QueryRow(ctx, query, sessionID, userID))
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	r.invalidate(ctx, session.RefreshToken)
+	return session, nil
+}
+
+// RevokeAllExcept revokes every active session of userID other than keepID and
+// returns the IDs of the revoked sessions. It fails with ErrSessionNotFound if
+// keepID is not an active session of the user.
+func (r *SessionRepository) RevokeAllExcept(ctx context.Context, userID, keepID int64) ([]int64, error) {
+	var revoked []int64
+	var tokens []string
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		revoked, tokens = nil, nil
+
+		var exists bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM sessions
+				WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+			)
+		`, keepID, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrSessionNotFound
+		}
+
+		rows, err := tx.Query(ctx, `
+			UPDATE sessions
+			SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+			RETURNING id, refresh_token
+		`, userID, keepID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int64
+			var token string
+			if err := rows.Scan(&id, &token); err != nil {
+				return err
+			}
+			revoked = append(revoked, id)
+			tokens = append(tokens, token)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidate(ctx, tokens...)
+	return revoked, nil
 }
 
 func (r *SessionRepository) RevokeAllByUserID(ctx context.Context, userID int64) error {
@@ -154,19 +462,32 @@ func (r *SessionRepository) RevokeAllByUserID(ctx context.Context, userID int64)
 		UPDATE sessions
 		SET revoked_at = CURRENT_TIMESTAMP
 		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING refresh_token
 	`
 
-	_, err := r.db.Exec(ctx, query, userID)
-	return err
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, tokens...)
+	return nil
 }
 
-func (r *SessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+// DeleteExpired removes sessions that expired or were revoked more than
+// retention ago. Recent ones are kept for the admin session history.
+func (r *SessionRepository) DeleteExpired(ctx context.Context, retention time.Duration) (int64, error) {
 	query := `
 		DELETE FROM sessions
-		WHERE expires_at < NOW() - INTERVAL '30 days'
+		WHERE expires_at < $1 OR revoked_at < $1
 	`
 
-	result, err := r.db.Exec(ctx, query)
+	result, err := r.db.Exec(ctx, query, time.Now().Add(-retention))
 	if err != nil {
 		return 0, err
 	}
@@ -190,5 +511,6 @@ func (r *SessionRepository) UpdateAccessToken(ctx context.Context, refreshToken,
 		return ErrSessionNotFound
 	}
 
+	r.invalidate(ctx, refreshToken)
 	return nil
 }
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type SigningKey struct {
+	ID          int64
+	KID         string
+	Algorithm   string
+	PrivateKey  string
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RetiredAt   *time.Time
+}
+
+type SigningKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSigningKeyRepository(db *pgxpool.Pool) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// ListUsable returns keys that have not been retired yet, newest first.
+// The newest key whose activation time has passed is the one used for
+// signing; newer ones are only published for verification until then.
+func (r *SigningKeyRepository) ListUsable(ctx context.Context) ([]*SigningKey, error) {
+	query := `
+		SELECT id, kid, algorithm, private_key, created_at, activates_at, retired_at
+		FROM signing_keys
+		WHERE retired_at IS NULL OR retired_at > NOW()
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*SigningKey
+	for rows.Next() {
+		key := &SigningKey{}
+		err := rows.Scan(
+			&key.ID,
+			&key.KID,
+			&key.Algorithm,
+			&key.PrivateKey,
+			&key.CreatedAt,
+			&key.ActivatesAt,
+			&key.RetiredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Rotate inserts key as the signing key from activateAt on. Keys that were in
+// use keep signing until then and verifying tokens until retireAt, after
+// which they are ignored.
+func (r *SigningKeyRepository) Rotate(ctx context.Context, key *SigningKey, activateAt, retireAt time.Time) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE signing_keys
+			SET retired_at = $1
+			WHERE retired_at IS NULL
+		`, retireAt)
+		if err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx, `
+			INSERT INTO signing_keys (kid, algorithm, private_key, activates_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, activates_at
+		`, key.KID, key.Algorithm, key.PrivateKey, activateAt).Scan(&key.ID, &key.CreatedAt, &key.ActivatesAt)
+	})
+}
+
+// DeleteRetired removes keys retired before the given time. Tokens they signed
+// have long expired by then.
+func (r *SigningKeyRepository) DeleteRetired(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM signing_keys
+		WHERE retired_at < $1
+	`
+
+	result, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
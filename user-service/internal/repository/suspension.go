@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+)
+
+var ErrSuspensionNotFound = errors.New("suspension not found")
+
+const suspensionColumns = `id, user_id, reason, moderator_id, starts_at, ends_at, lifted_at, lifted_by, created_at`
+
+// activeSuspensionCondition matches suspensions that are in force right now.
+const activeSuspensionCondition = `lifted_at IS NULL AND starts_at <= NOW() AND (ends_at IS NULL OR ends_at > NOW())`
+
+type SuspensionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSuspensionRepository(db *pgxpool.Pool) *SuspensionRepository {
+	return &SuspensionRepository{db: db}
+}
+
+func scanSuspension(row pgx.Row) (*models.Suspension, error) {
+	s := &models.Suspension{}
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.Reason,
+		&s.ModeratorID,
+		&s.StartsAt,
+		&s.EndsAt,
+		&s.LiftedAt,
+		&s.LiftedBy,
+		&s.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSuspensionNotFound
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *SuspensionRepository) Create(ctx context.Context, s *models.Suspension) error {
+	query := `
+		INSERT INTO user_suspensions (user_id, reason, moderator_id, ends_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, starts_at, created_at
+	`
+
+	return r.db.QueryRow(ctx, query, s.UserID, s.Reason, s.ModeratorID, s.EndsAt).
+		Scan(&s.ID, &s.StartsAt, &s.CreatedAt)
+}
+
+// GetActiveByUserID returns the suspension that keeps the user locked out the
+// longest. Permanent bans win over temporary ones.
+func (r *SuspensionRepository) GetActiveByUserID(ctx context.Context, userID int64) (*models.Suspension, error) {
+	query := `
+		SELECT ` + suspensionColumns + `
+		FROM user_suspensions
+		WHERE user_id = $1 AND ` + activeSuspensionCondition + `
+		ORDER BY ends_at DESC NULLS FIRST
+		LIMIT 1
+	`
+
+	return scanSuspension(r.db.QueryRow(ctx, query, userID))
+}
+
+func (r *SuspensionRepository) ListByUserID(ctx context.Context, userID int64) ([]*models.Suspension, error) {
+	query := `
+		SELECT ` + suspensionColumns + `
+		FROM user_suspensions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suspensions []*models.Suspension
+	for rows.Next() {
+		s, err := scanSuspension(rows)
+		if err != nil {
+			return nil, err
+		}
+		suspensions = append(suspensions, s)
+	}
+
+	return suspensions, rows.Err()
+}
+
+// LiftActive ends every suspension currently in force for the user.
+func (r *SuspensionRepository) LiftActive(ctx context.Context, userID int64, liftedBy *int64) error {
+	query := `
+		UPDATE user_suspensions
+		SET lifted_at = CURRENT_TIMESTAMP, lifted_by = $2
+		WHERE user_id = $1 AND ` + activeSuspensionCondition
+
+	result, err := r.db.Exec(ctx, query, userID, liftedBy)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrSuspensionNotFound
+	}
+
+	return nil
+}
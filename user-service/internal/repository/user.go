@@ -3,28 +3,97 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zhanserikAmangeldi/user-service/internal/cache"
 	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var ErrUserNotFound = errors.New("user not found")
 var ErrUserAlreadyExists = errors.New("user already exists")
 
+const userColumns = `id, username, email, password_hash, display_name, avatar_url,
+		       bio, presence_override, role, COALESCE(is_verified, FALSE), last_seen_at,
+		       created_at, updated_at, deleted_at`
+
+const (
+	UserStateActive    = "active"
+	UserStateDeleted   = "deleted"
+	UserStateSuspended = "suspended"
+)
+
+// UserFilter narrows down List results. Zero values mean "no filter".
+type UserFilter struct {
+	State         string
+	IsVerified    *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	AfterID       int64
+	Limit         int
+}
+
 type UserRepository struct {
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	cache *cache.Cache
+}
+
+// NewUserRepository returns a repository that serves GetByID from profiles, a
+// read-through cache invalidated by every write below. profiles may be nil.
+func NewUserRepository(db *pgxpool.Pool, profiles *cache.Cache) *UserRepository {
+	return &UserRepository{db: db, cache: profiles}
 }
 
-func NewUserRepository(db *pgxpool.Pool) *UserRepository {
-	return &UserRepository{db: db}
+func userCacheKey(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func (r *UserRepository) invalidate(ctx context.Context, userIDs ...int64) {
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = userCacheKey(id)
+	}
+	r.cache.Delete(ctx, keys...)
+}
+
+func scanUser(row pgx.Row) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.DisplayName,
+		&user.AvatarURL,
+		&user.Bio,
+		&user.PresenceOverride,
+		&user.Role,
+		&user.IsVerified,
+		&user.LastSeenAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.DeletedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	user.Status = "offline"
+	return user, nil
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (username, email, password_hash, display_name, status)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at
+		INSERT INTO users (username, email, password_hash, display_name)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, role, created_at, updated_at
 	`
 
 	err := r.db.QueryRow(ctx, query,
@@ -32,8 +101,7 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 		user.Email,
 		user.PasswordHash,
 		user.DisplayName,
-		"offline",
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		if err.Error() == "ERROR: duplicate key value violates unique constraint \"users_username_key\" (SQLSTATE 23505)" {
@@ -46,109 +114,229 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	return nil
 }
 
-func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+// FindTaken returns which of the given usernames and emails already belong to
+// an account, including soft-deleted ones since they still hold the unique keys.
+func (r *UserRepository) FindTaken(ctx context.Context, usernames, emails []string) (map[string]bool, map[string]bool, error) {
 	query := `
-		SELECT id, username, email, password_hash, display_name, avatar_url, 
-		       bio, status, last_seen_at, created_at, updated_at
+		SELECT username, email
 		FROM users
-		WHERE id = $1 AND deleted_at IS NULL
+		WHERE username = ANY($1) OR email = ANY($2)
 	`
 
+	rows, err := r.db.Query(ctx, query, usernames, emails)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	takenUsernames := make(map[string]bool)
+	takenEmails := make(map[string]bool)
+	for rows.Next() {
+		var username, email string
+		if err := rows.Scan(&username, &email); err != nil {
+			return nil, nil, err
+		}
+		takenUsernames[username] = true
+		takenEmails[email] = true
+	}
+
+	return takenUsernames, takenEmails, rows.Err()
+}
+
+// CopyFrom inserts users in bulk with the COPY protocol and fills in their IDs.
+// Unlike Create it keeps IsVerified and CreatedAt as given. A duplicate key
+// fails the whole batch.
+func (r *UserRepository) CopyFrom(ctx context.Context, users []*models.User) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		rows := make([][]any, 0, len(users))
+		usernames := make([]string, 0, len(users))
+		for _, user := range users {
+			rows = append(rows, []any{
+				user.Username,
+				user.Email,
+				user.PasswordHash,
+				user.DisplayName,
+				user.IsVerified,
+				user.CreatedAt,
+				user.CreatedAt,
+			})
+			usernames = append(usernames, user.Username)
+		}
+
+		_, err := tx.CopyFrom(ctx,
+			pgx.Identifier{"users"},
+			[]string{"username", "email", "password_hash", "display_name", "is_verified", "created_at", "updated_at"},
+			pgx.CopyFromRows(rows),
+		)
+		if err != nil {
+			return err
+		}
+
+		idRows, err := tx.Query(ctx, `SELECT id, username, role FROM users WHERE username = ANY($1)`, usernames)
+		if err != nil {
+			return err
+		}
+		defer idRows.Close()
+
+		byUsername := make(map[string]*models.User, len(users))
+		for _, user := range users {
+			byUsername[user.Username] = user
+		}
+		for idRows.Next() {
+			var id int64
+			var username, role string
+			if err := idRows.Scan(&id, &username, &role); err != nil {
+				return err
+			}
+			if user, ok := byUsername[username]; ok {
+				user.ID = id
+				user.Role = role
+				user.Status = "offline"
+				user.UpdatedAt = user.CreatedAt
+			}
+		}
+
+		return idRows.Err()
+	})
+}
+
+// GetByID returns an active user's profile. The password hash is left out
+// since the profile may come from the cache; credential checks go through
+// GetByEmail or GetByUsername.
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.DisplayName,
-		&user.AvatarURL,
-		&user.Bio,
-		&user.Status,
-		&user.LastSeenAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	if r.cache.Get(ctx, userCacheKey(id), user) {
+		return user, nil
+	}
 
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	user, err := scanUser(r.db.QueryRow(ctx, query, id))
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrUserNotFound
-		}
 		return nil, err
 	}
 
+	user.PasswordHash = ""
+	r.cache.Set(ctx, userCacheKey(id), user, 0)
 	return user, nil
 }
 
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+// GetByIDUncached is GetByID without the cache, for checks that must see a
+// change made on another instance at once.
+func (r *UserRepository) GetByIDUncached(ctx context.Context, id int64) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, display_name, avatar_url,
-		       bio, status, last_seen_at, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE email = $1 AND deleted_at IS NULL
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	user := &models.User{}
-	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.DisplayName,
-		&user.AvatarURL,
-		&user.Bio,
-		&user.Status,
-		&user.LastSeenAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	user, err := scanUser(r.db.QueryRow(ctx, query, id))
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrUserNotFound
-		}
 		return nil, err
 	}
 
+	user.PasswordHash = ""
 	return user, nil
 }
 
+// GetByIDUnscoped returns the user even if it has been soft-deleted.
+func (r *UserRepository) GetByIDUnscoped(ctx context.Context, id int64) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1
+	`
+
+	return scanUser(r.db.QueryRow(ctx, query, id))
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1 AND deleted_at IS NULL
+	`
+
+	return scanUser(r.db.QueryRow(ctx, query, email))
+}
+
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, display_name, avatar_url,
-		       bio, status, last_seen_at, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE username = $1 AND deleted_at IS NULL
 	`
 
-	user := &models.User{}
-	err := r.db.QueryRow(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.PasswordHash,
-		&user.DisplayName,
-		&user.AvatarURL,
-		&user.Bio,
-		&user.Status,
-		&user.LastSeenAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	return scanUser(r.db.QueryRow(ctx, query, username))
+}
 
+// List returns users ordered from newest to oldest, starting after filter.AfterID.
+func (r *UserRepository) List(ctx context.Context, filter UserFilter) ([]*models.User, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	switch filter.State {
+	case UserStateActive:
+		conditions = append(conditions, "deleted_at IS NULL")
+	case UserStateDeleted:
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	case UserStateSuspended:
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM user_suspensions
+			WHERE user_suspensions.user_id = users.id AND `+activeSuspensionCondition+`
+		)`)
+	}
+	if filter.IsVerified != nil {
+		addCondition("COALESCE(is_verified, FALSE) = $%d", *filter.IsVerified)
+	}
+	if filter.CreatedAfter != nil {
+		addCondition("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		addCondition("created_at < $%d", *filter.CreatedBefore)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, ErrUserNotFound
-		}
 		return nil, err
 	}
+	defer rows.Close()
 
-	return user, nil
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
 }
 
 func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users
-		SET display_name = $2, avatar_url = $3, bio = $4, status = $5, updated_at = CURRENT_TIMESTAMP
+		SET display_name = $2, avatar_url = $3, bio = $4, presence_override = $5, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1 AND deleted_at IS NULL
 		RETURNING updated_at
 	`
@@ -158,7 +346,7 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 		user.DisplayName,
 		user.AvatarURL,
 		user.Bio,
-		user.Status,
+		user.PresenceOverride,
 	).Scan(&user.UpdatedAt)
 
 	if err != nil {
@@ -168,18 +356,45 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 		return err
 	}
 
+	r.invalidate(ctx, user.ID)
 	return nil
 }
 
-func (r *UserRepository) UpdateLastSeen(ctx context.Context, userID int64) error {
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
 	query := `
 		UPDATE users
-		SET last_seen_at = $2
+		SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	_, err := r.db.Exec(ctx, query, userID, time.Now())
-	return err
+	result, err := r.db.Exec(ctx, query, userID, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	r.invalidate(ctx, userID)
+	return nil
+}
+
+// UpdateLastSeen moves last_seen_at forward to seenAt; an older seenAt,
+// written back late, is ignored.
+func (r *UserRepository) UpdateLastSeen(ctx context.Context, userID int64, seenAt time.Time) error {
+	query := `
+		UPDATE users
+		SET last_seen_at = $2
+		WHERE id = $1 AND deleted_at IS NULL AND (last_seen_at IS NULL OR last_seen_at < $2)
+	`
+
+	if _, err := r.db.Exec(ctx, query, userID, seenAt); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userID)
+	return nil
 }
 
 func (r *UserRepository) MarkVerified(ctx context.Context, userID int64) error {
@@ -188,6 +403,70 @@ func (r *UserRepository) MarkVerified(ctx context.Context, userID int64) error {
 		SET is_verified = TRUE, updated_at = NOW()
 		WHERE id = $1
 	`
-	_, err := r.db.Exec(ctx, query, userID)
-	return err
+	if _, err := r.db.Exec(ctx, query, userID); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, userID)
+	return nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, userID int64, role string) error {
+	query := `
+		UPDATE users
+		SET role = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	r.invalidate(ctx, userID)
+	return nil
+}
+
+func (r *UserRepository) SoftDelete(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	r.invalidate(ctx, userID)
+	return nil
+}
+
+func (r *UserRepository) Restore(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+
+	r.invalidate(ctx, userID)
+	return nil
 }
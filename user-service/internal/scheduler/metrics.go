@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+var (
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_runs_total",
+		Help: "Job runs by result.",
+	}, []string{"job", "result"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scheduler_job_duration_seconds",
+		Help:    "Duration of job runs.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	}, []string{"job"})
+
+	jobItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_job_items_total",
+		Help: "Items processed by jobs, such as deleted rows.",
+	}, []string{"job"})
+
+	jobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scheduler_job_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful run.",
+	}, []string{"job"})
+
+	jobInterval = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scheduler_job_interval_seconds",
+		Help: "Configured interval of each job.",
+	}, []string{"job"})
+
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "scheduler_leader",
+		Help: "1 if this instance runs the scheduled jobs.",
+	})
+)
+
+func observeRun(job string, duration time.Duration, items int64, err error) {
+	jobDuration.WithLabelValues(job).Observe(duration.Seconds())
+	jobItems.WithLabelValues(job).Add(float64(items))
+
+	if err != nil {
+		jobRuns.WithLabelValues(job, "error").Inc()
+		return
+	}
+	jobRuns.WithLabelValues(job, "success").Inc()
+	jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
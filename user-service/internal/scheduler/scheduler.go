@@ -0,0 +1,297 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	leaderKey = "scheduler:leader"
+	leaderTTL = 30 * time.Second
+	// runLockTTL bounds how long a crashed instance can block a job.
+	runLockTTL = 15 * time.Minute
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobRunning      = errors.New("job is already running")
+	ErrDuplicatedJob   = errors.New("job already registered")
+	ErrLockUnavailable = errors.New("job lock unavailable")
+)
+
+// releaseScript deletes a lock only if it is still held by the caller.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// renewScript extends a lock only if it is still held by the caller.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Job is a periodic task. Run returns the number of items it processed.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) (int64, error)
+}
+
+// JobStatus is what this instance knows about a job.
+type JobStatus struct {
+	Name           string     `json:"name"`
+	Interval       string     `json:"interval"`
+	Running        bool       `json:"running"`
+	Runs           int64      `json:"runs"`
+	Failures       int64      `json:"failures"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastItems      int64      `json:"last_items"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+type jobState struct {
+	job Job
+
+	mu     sync.Mutex
+	status JobStatus
+}
+
+// Scheduler runs registered jobs on their interval on exactly one replica: the
+// one holding the leader lock in Redis. Any replica can run a job on demand
+// with Trigger; a per-job lock keeps two runs of the same job from overlapping.
+type Scheduler struct {
+	redis      *redis.Client
+	instanceID string
+
+	leader atomic.Bool
+
+	mu   sync.RWMutex
+	jobs map[string]*jobState
+}
+
+func New(redisClient *redis.Client, instanceID string) *Scheduler {
+	return &Scheduler{
+		redis:      redisClient,
+		instanceID: instanceID,
+		jobs:       make(map[string]*jobState),
+	}
+}
+
+func (s *Scheduler) Register(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[job.Name]; ok {
+		return ErrDuplicatedJob
+	}
+
+	s.jobs[job.Name] = &jobState{
+		job:    job,
+		status: JobStatus{Name: job.Name, Interval: job.Interval.String()},
+	}
+	jobInterval.WithLabelValues(job.Name).Set(job.Interval.Seconds())
+	return nil
+}
+
+func (s *Scheduler) InstanceID() string {
+	return s.instanceID
+}
+
+// IsLeader reports whether this instance currently runs the periodic jobs.
+func (s *Scheduler) IsLeader() bool {
+	return s.leader.Load()
+}
+
+// Run campaigns for leadership and runs the jobs until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.RLock()
+	states := make([]*jobState, 0, len(s.jobs))
+	for _, state := range s.jobs {
+		states = append(states, state)
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, state := range states {
+		wg.Add(1)
+		go func(state *jobState) {
+			defer wg.Done()
+			s.loop(ctx, state)
+		}(state)
+	}
+
+	s.campaign(ctx)
+	wg.Wait()
+}
+
+func (s *Scheduler) campaign(ctx context.Context) {
+	ticker := time.NewTicker(leaderTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		s.refreshLeadership(ctx)
+
+		select {
+		case <-ctx.Done():
+			if s.leader.Load() {
+				_ = releaseScript.Run(context.WithoutCancel(ctx), s.redis, []string{leaderKey}, s.instanceID).Err()
+				s.setLeader(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) refreshLeadership(ctx context.Context) {
+	if s.leader.Load() {
+		renewed, err := renewScript.Run(ctx, s.redis, []string{leaderKey}, s.instanceID, leaderTTL.Milliseconds()).Int()
+		if err == nil && renewed == 1 {
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to renew scheduler leadership: %v", err)
+		}
+		s.setLeader(false)
+	}
+
+	acquired, err := s.redis.SetNX(ctx, leaderKey, s.instanceID, leaderTTL).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[ERROR] Failed to acquire scheduler leadership: %v", err)
+		}
+		return
+	}
+	if acquired {
+		s.setLeader(true)
+	}
+}
+
+func (s *Scheduler) setLeader(leader bool) {
+	if s.leader.Swap(leader) == leader {
+		return
+	}
+
+	if leader {
+		leaderGauge.Set(1)
+		log.Printf("[INFO] Scheduler: %s became leader", s.instanceID)
+	} else {
+		leaderGauge.Set(0)
+		log.Printf("[INFO] Scheduler: %s is no longer leader", s.instanceID)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, state *jobState) {
+	ticker := time.NewTicker(state.job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.leader.Load() {
+				continue
+			}
+			if _, err := s.run(ctx, state); err != nil && !errors.Is(err, ErrJobRunning) {
+				log.Printf("[ERROR] Scheduled job %s failed: %v", state.job.Name, err)
+			}
+		}
+	}
+}
+
+// Trigger runs a job now on this instance, whether or not it is the leader,
+// and returns its status afterwards.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*JobStatus, error) {
+	s.mu.RLock()
+	state, ok := s.jobs[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	// A failed job still has a status to report; only an error that kept the
+	// job from running at all is returned.
+	status, err := s.run(ctx, state)
+	if status == nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Jobs returns the status of every registered job, ordered by name.
+func (s *Scheduler) Jobs() []*JobStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]*JobStatus, 0, len(s.jobs))
+	for _, state := range s.jobs {
+		state.mu.Lock()
+		status := state.status
+		state.mu.Unlock()
+		statuses = append(statuses, &status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func (s *Scheduler) run(ctx context.Context, state *jobState) (*JobStatus, error) {
+	name := state.job.Name
+	lockKey := "scheduler:job:" + name
+
+	acquired, err := s.redis.SetNX(ctx, lockKey, s.instanceID, runLockTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLockUnavailable, err)
+	}
+	if !acquired {
+		return nil, ErrJobRunning
+	}
+	defer func() {
+		_ = releaseScript.Run(context.WithoutCancel(ctx), s.redis, []string{lockKey}, s.instanceID).Err()
+	}()
+
+	state.mu.Lock()
+	state.status.Running = true
+	state.mu.Unlock()
+
+	startedAt := time.Now()
+	items, runErr := state.job.Run(ctx)
+	duration := time.Since(startedAt)
+
+	state.mu.Lock()
+	state.status.Running = false
+	state.status.Runs++
+	state.status.LastRunAt = &startedAt
+	state.status.LastDurationMs = duration.Milliseconds()
+	state.status.LastItems = items
+	state.status.LastError = ""
+	if runErr != nil {
+		state.status.Failures++
+		state.status.LastError = runErr.Error()
+	}
+	status := state.status
+	state.mu.Unlock()
+
+	observeRun(name, duration, items, runErr)
+
+	if runErr == nil {
+		log.Printf("[INFO] Job %s finished in %s, %d items", name, duration.Round(time.Millisecond), items)
+	}
+	return &status, runErr
+}
@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAdminPageSize    = 50
+	defaultImpersonationTTL = 10 * time.Minute
+)
+
+var (
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrCannotSuspendSelf   = errors.New("admins cannot suspend themselves")
+	ErrCannotChangeOwnRole = errors.New("admins cannot change their own role")
+	ErrSuspensionEndInPast = errors.New("suspension end must be in the future")
+)
+
+// Actor identifies who performed an administrative action.
+type Actor struct {
+	UserID    int64
+	IPAddress *string
+	UserAgent *string
+}
+
+type AdminService struct {
+	userRepo       *repository.UserRepository
+	sessionRepo    *repository.SessionRepository
+	auditRepo      *repository.AuditLogRepository
+	suspensionRepo *repository.SuspensionRepository
+	authService    *AuthService
+}
+
+func NewAdminService(
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	auditRepo *repository.AuditLogRepository,
+	suspensionRepo *repository.SuspensionRepository,
+	authService *AuthService,
+) *AdminService {
+	return &AdminService{
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		auditRepo:      auditRepo,
+		suspensionRepo: suspensionRepo,
+		authService:    authService,
+	}
+}
+
+func (s *AdminService) ListUsers(ctx context.Context, query *dto.AdminListUsersQuery) (*dto.AdminUserListResponse, error) {
+	afterID, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultAdminPageSize
+	}
+
+	users, err := s.userRepo.List(ctx, repository.UserFilter{
+		State:         query.Status,
+		IsVerified:    query.Verified,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		AfterID:       afterID,
+		Limit:         limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.AdminUserListResponse{Users: users}
+	if resp.Users == nil {
+		resp.Users = []*models.User{}
+	}
+	if len(users) == limit {
+		resp.NextCursor = encodeCursor(users[len(users)-1].ID)
+	}
+
+	return resp, nil
+}
+
+// CreateUser creates an account directly, without a verification email or a session.
+func (s *AdminService) CreateUser(ctx context.Context, actor Actor, req *dto.AdminCreateUserRequest) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: string(hashedPassword),
+	}
+	if req.DisplayName != "" {
+		user.DisplayName = &req.DisplayName
+	}
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrUserAlreadyExists) {
+			return nil, ErrAlreadyUserExists
+		}
+		return nil, err
+	}
+
+	if req.Role != "" && req.Role != user.Role {
+		if err := s.userRepo.SetRole(ctx, user.ID, req.Role); err != nil {
+			return nil, err
+		}
+		user.Role = req.Role
+	}
+
+	if req.Verified {
+		if err := s.userRepo.MarkVerified(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		user.IsVerified = true
+	}
+
+	s.audit(ctx, actor, models.AuditActionCreateUser, user.ID, map[string]any{
+		"role":     user.Role,
+		"verified": user.IsVerified,
+	})
+	return user, nil
+}
+
+func (s *AdminService) SetRole(ctx context.Context, actor Actor, userID int64, role string) error {
+	if actor.UserID == userID {
+		return ErrCannotChangeOwnRole
+	}
+
+	if err := s.userRepo.SetRole(ctx, userID, role); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, models.AuditActionSetRole, userID, map[string]any{"role": role})
+	return nil
+}
+
+func (s *AdminService) GetUserDetails(ctx context.Context, userID int64) (*dto.AdminUserDetailsResponse, error) {
+	user, err := s.userRepo.GetByIDUnscoped(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.GetAllByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionInfos := make([]*models.SessionInfo, 0, len(sessions))
+	for _, sess := range sessions {
+		sessionInfos = append(sessionInfos, newSessionInfo(sess, false))
+	}
+
+	suspension, err := s.suspensionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, repository.ErrSuspensionNotFound) {
+		return nil, err
+	}
+
+	return &dto.AdminUserDetailsResponse{
+		User:             user,
+		Sessions:         sessionInfos,
+		ActiveSuspension: suspension,
+	}, nil
+}
+
+func (s *AdminService) VerifyEmail(ctx context.Context, actor Actor, userID int64) error {
+	if _, err := s.userRepo.GetByIDUnscoped(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.MarkVerified(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, models.AuditActionVerifyEmail, userID, nil)
+	return nil
+}
+
+// ResetPassword sets a new password and signs the user out everywhere,
+// so whoever knew the old password loses access immediately.
+func (s *AdminService) ResetPassword(ctx context.Context, actor Actor, userID int64, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, models.AuditActionResetPassword, userID, nil)
+	return nil
+}
+
+func (s *AdminService) RevokeSessions(ctx context.Context, actor Actor, userID int64) error {
+	if _, err := s.userRepo.GetByIDUnscoped(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, models.AuditActionRevokeSessions, userID, nil)
+	return nil
+}
+
+func (s *AdminService) DeleteUser(ctx context.Context, actor Actor, userID int64) error {
+	if err := s.userRepo.SoftDelete(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.authService.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, models.AuditActionDeleteUser, userID, nil)
+	return nil
+}
+
+func (s *AdminService) RestoreUser(ctx context.Context, actor Actor, userID int64) error {
+	if err := s.userRepo.Restore(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, models.AuditActionRestoreUser, userID, nil)
+	return nil
+}
+
+func (s *AdminService) SuspendUser(ctx context.Context, actor Actor, userID int64, req *dto.AdminSuspendUserRequest) (*models.Suspension, error) {
+	if actor.UserID == userID {
+		return nil, ErrCannotSuspendSelf
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(time.Now()) {
+		return nil, ErrSuspensionEndInPast
+	}
+
+	if _, err := s.userRepo.GetByIDUnscoped(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	suspension := &models.Suspension{
+		UserID: userID,
+		Reason: req.Reason,
+		EndsAt: req.EndsAt,
+	}
+	if actor.UserID != 0 {
+		suspension.ModeratorID = &actor.UserID
+	}
+
+	if err := s.authService.SuspendUser(ctx, suspension); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, actor, models.AuditActionSuspendUser, userID, map[string]any{
+		"suspension_id": suspension.ID,
+		"reason":        suspension.Reason,
+		"ends_at":       suspension.EndsAt,
+	})
+	return suspension, nil
+}
+
+func (s *AdminService) LiftSuspension(ctx context.Context, actor Actor, userID int64) error {
+	var liftedBy *int64
+	if actor.UserID != 0 {
+		liftedBy = &actor.UserID
+	}
+
+	if err := s.authService.LiftSuspension(ctx, userID, liftedBy); err != nil {
+		return err
+	}
+
+	s.audit(ctx, actor, models.AuditActionLiftSuspension, userID, nil)
+	return nil
+}
+
+func (s *AdminService) ListSuspensions(ctx context.Context, userID int64) (*dto.SuspensionListResponse, error) {
+	suspensions, err := s.suspensionRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if suspensions == nil {
+		suspensions = []*models.Suspension{}
+	}
+
+	return &dto.SuspensionListResponse{
+		Suspensions: suspensions,
+		Total:       len(suspensions),
+	}, nil
+}
+
+func (s *AdminService) Impersonate(ctx context.Context, actor Actor, userID int64, req *dto.AdminImpersonateRequest) (*dto.ImpersonationResponse, error) {
+	ttl := defaultImpersonationTTL
+	if req.DurationMinutes > 0 {
+		ttl = time.Duration(req.DurationMinutes) * time.Minute
+	}
+
+	resp, err := s.authService.IssueImpersonationToken(ctx, userID, actor.UserID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, actor, models.AuditActionImpersonate, userID, map[string]any{
+		"reason":     req.Reason,
+		"expires_in": resp.ExpiresIn,
+	})
+	return resp, nil
+}
+
+func (s *AdminService) ListAuditLogs(ctx context.Context, query *dto.AdminListAuditLogsQuery) (*dto.AuditLogListResponse, error) {
+	afterID, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultAdminPageSize
+	}
+
+	entries, err := s.auditRepo.List(ctx, repository.AuditLogFilter{
+		ActorID:      query.ActorID,
+		TargetUserID: query.TargetUserID,
+		Action:       query.Action,
+		AfterID:      afterID,
+		Limit:        limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.AuditLogListResponse{Entries: entries}
+	if resp.Entries == nil {
+		resp.Entries = []*models.AuditLog{}
+	}
+	if len(entries) == limit {
+		resp.NextCursor = encodeCursor(entries[len(entries)-1].ID)
+	}
+
+	return resp, nil
+}
+
+// audit records an administrative action. A failure to write the entry is
+// logged rather than returned, because the action itself has already happened.
+// RecordAction writes an audit log entry for an admin action that is carried
+// out elsewhere, such as running a maintenance job.
+func (s *AdminService) RecordAction(ctx context.Context, actor Actor, action string, targetUserID int64, metadata map[string]any) {
+	s.audit(ctx, actor, action, targetUserID, metadata)
+}
+
+func (s *AdminService) audit(ctx context.Context, actor Actor, action string, targetUserID int64, metadata map[string]any) {
+	entry := &models.AuditLog{
+		Action:    action,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+	}
+	if actor.UserID != 0 {
+		entry.ActorID = &actor.UserID
+	}
+	if targetUserID != 0 {
+		entry.TargetUserID = &targetUserID
+	}
+	if metadata != nil {
+		raw, err := json.Marshal(metadata)
+		if err == nil {
+			entry.Metadata = raw
+		}
+	}
+
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		log.Printf("[ERROR] Failed to write audit log (action=%s, actorID=%d, targetUserID=%d): %v",
+			action, actor.UserID, targetUserID, err)
+	}
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
@@ -8,9 +8,11 @@ import (
 	"fmt"
 	"github.com/redis/go-redis/v9"
 	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/geoip"
 	"github.com/zhanserikAmangeldi/user-service/internal/models"
 	"github.com/zhanserikAmangeldi/user-service/internal/repository"
 	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
 	"golang.org/x/crypto/bcrypt"
 	"log"
 	"strings"
@@ -18,21 +20,57 @@ import (
 )
 
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrAlreadyUserExists  = errors.New("user already exists")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrAlreadyUserExists   = errors.New("user already exists")
+	ErrAccountSuspended    = errors.New("account suspended")
+	ErrCannotImpersonate   = errors.New("this user cannot be impersonated")
+	ErrEmailNotSent        = errors.New("email could not be sent")
+	ErrSessionLimitReached = errors.New("maximum number of active sessions reached")
+	ErrDPoPKeyMismatch     = errors.New("refresh token is bound to a different DPoP key")
 )
 
+// SuspendedError is returned when a suspended user tries to authenticate.
+// It matches ErrAccountSuspended with errors.Is.
+type SuspendedError struct {
+	Reason string
+	EndsAt *time.Time
+}
+
+func (e *SuspendedError) Error() string {
+	if e.EndsAt == nil {
+		return "account suspended permanently"
+	}
+	return fmt.Sprintf("account suspended until %s", e.EndsAt.Format(time.RFC3339))
+}
+
+func (e *SuspendedError) Is(target error) bool {
+	return target == ErrAccountSuspended
+}
+
 type AuthService struct {
-	userRepo     *repository.UserRepository
-	sessionRepo  *repository.SessionRepository
-	tokenManager *jwt.TokenManager
-	emailRepo    *repository.EmailVerificationRepository
-	emailSender  EmailSender
-	redisClient  *redis.Client
+	userRepo       *repository.UserRepository
+	sessionRepo    *repository.SessionRepository
+	tokenManager   *jwt.TokenManager
+	emailRepo      *repository.EmailVerificationRepository
+	suspensionRepo *repository.SuspensionRepository
+	emailSender    EmailSender
+	redisClient    *redis.Client
+	revocations    *revocation.Store
+	geoLocator     *geoip.Locator
+	presence       *PresenceService
+	sessionPolicy  SessionPolicy
+}
+
+// SessionPolicy controls how long sessions live and how many a user may have.
+type SessionPolicy struct {
+	AbsoluteLifetime time.Duration
+	IdleTimeout      time.Duration
+	Limit            repository.SessionLimit
 }
 
 type EmailSender interface {
 	SendVerificationEmail(to, username, token string) error
+	SendWelcomeEmail(to, username string) error
 }
 
 func NewAuthService(
@@ -40,20 +78,32 @@ func NewAuthService(
 	sessionRepo *repository.SessionRepository,
 	tokenManager *jwt.TokenManager,
 	emailRepo *repository.EmailVerificationRepository,
+	suspensionRepo *repository.SuspensionRepository,
 	emailSender EmailSender,
 	redisClient *redis.Client,
+	revocations *revocation.Store,
+	geoLocator *geoip.Locator,
+	presenceService *PresenceService,
+	sessionPolicy SessionPolicy,
 ) *AuthService {
 	return &AuthService{
-		userRepo:     userRepo,
-		sessionRepo:  sessionRepo,
-		tokenManager: tokenManager,
-		emailRepo:    emailRepo,
-		emailSender:  emailSender,
-		redisClient:  redisClient,
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		tokenManager:   tokenManager,
+		emailRepo:      emailRepo,
+		suspensionRepo: suspensionRepo,
+		emailSender:    emailSender,
+		redisClient:    redisClient,
+		revocations:    revocations,
+		geoLocator:     geoLocator,
+		presence:       presenceService,
+		sessionPolicy:  sessionPolicy,
 	}
 }
 
-func (s *AuthService) Register(ctx context.Context, req *dto.RegisterUserRequest, userAgent, ipAddress *string) (*dto.AuthResponse, error) {
+// Register creates the user and signs them in. A non-empty dpopKey binds the
+// issued tokens to that DPoP key thumbprint; Login behaves the same way.
+func (s *AuthService) Register(ctx context.Context, req *dto.RegisterUserRequest, userAgent, ipAddress *string, dpopKey string) (*dto.AuthResponse, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -77,54 +127,14 @@ func (s *AuthService) Register(ctx context.Context, req *dto.RegisterUserRequest
 		return nil, err
 	}
 
-	token, err := s.generateVerificationToken()
-	if err != nil {
-		return nil, err
-	}
-
-	ev := &models.EmailVerification{
-		UserID:    user.ID,
-		Token:     token,
-		ExpiresAt: time.Now().Add(time.Hour * 24),
-	}
-	if err := s.emailRepo.Create(ctx, ev); err != nil {
-		return nil, err
-	}
-
-	_ = s.emailSender.SendVerificationEmail(user.Email, user.Username, token)
-
-	accessToken, expiresAt, err := s.tokenManager.GenerateAccessToken(user.ID, user.Username, user.Email)
-	if err != nil {
+	if err := s.SendVerificationEmail(ctx, user); err != nil && !errors.Is(err, ErrEmailNotSent) {
 		return nil, err
 	}
 
-	refreshToken, _, err := s.tokenManager.GenerateRefreshToken(user.ID, user.Username, user.Email)
-	if err != nil {
-		return nil, err
-	}
-
-	session := &repository.Session{
-		UserID:       user.ID,
-		RefreshToken: refreshToken,
-		AccessToken:  accessToken,
-		UserAgent:    userAgent,
-		IPAddress:    ipAddress,
-		ExpiresAt:    expiresAt,
-	}
-
-	if err := s.sessionRepo.Create(ctx, session); err != nil {
-		return nil, err
-	}
-
-	return &dto.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    int64(expiresAt.Sub(expiresAt.Add(-24 * 3600)).Seconds()),
-		User:         user,
-	}, nil
+	return s.startSession(ctx, user, userAgent, ipAddress, req.DeviceName, dpopKey)
 }
 
-func (s *AuthService) Login(ctx context.Context, req *dto.LoginRequest, userAgent, ipAddress *string) (*dto.AuthResponse, error) {
+func (s *AuthService) Login(ctx context.Context, req *dto.LoginRequest, userAgent, ipAddress *string, dpopKey string) (*dto.AuthResponse, error) {
 	var user *models.User
 	var err error
 
@@ -146,41 +156,96 @@ func (s *AuthService) Login(ctx context.Context, req *dto.LoginRequest, userAgen
 		return nil, ErrInvalidCredentials
 	}
 
-	accessToken, expiresAt, err := s.tokenManager.GenerateAccessToken(user.ID, user.Username, user.Email)
+	if err := s.checkSuspension(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	authResp, err := s.startSession(ctx, user, userAgent, ipAddress, req.DeviceName, dpopKey)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, refreshExpiresAt, err := s.tokenManager.GenerateRefreshToken(user.ID, user.Username, user.Email)
+	s.presence.Touch(ctx, user.ID)
+
+	return authResp, nil
+}
+
+// startSession issues a token pair for a new session on the calling device.
+func (s *AuthService) startSession(ctx context.Context, user *models.User, userAgent, ipAddress *string, deviceName, dpopKey string) (*dto.AuthResponse, error) {
+	sessionID, err := s.sessionRepo.NextID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	session := &repository.Session{
-		UserID:       user.ID,
-		RefreshToken: refreshToken,
-		AccessToken:  accessToken,
-		UserAgent:    userAgent,
-		IPAddress:    ipAddress,
-		ExpiresAt:    refreshExpiresAt,
+		ID:                sessionID,
+		UserID:            user.ID,
+		UserAgent:         userAgent,
+		IPAddress:         ipAddress,
+		AbsoluteExpiresAt: now.Add(s.sessionPolicy.AbsoluteLifetime),
+		IdleTimeout:       s.sessionPolicy.IdleTimeout,
+	}
+	session.ExpiresAt = session.SlidingExpiry(now)
+	s.describeDevice(session, deviceName)
+
+	accessToken, accessExpiresAt, err := s.tokenManager.GenerateAccessToken(user.ID, user.Username, user.Email,
+		jwt.WithSessionID(sessionID), jwt.WithKeyThumbprint(dpopKey))
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.sessionRepo.Create(ctx, session); err != nil {
+	refreshToken, _, err := s.tokenManager.GenerateRefreshToken(user.ID, user.Username, user.Email,
+		jwt.WithTTL(session.ExpiresAt.Sub(now)), jwt.WithSessionID(sessionID), jwt.WithKeyThumbprint(dpopKey))
+	if err != nil {
 		return nil, err
 	}
 
-	_ = s.userRepo.UpdateLastSeen(ctx, user.ID)
+	session.AccessToken = accessToken
+	session.RefreshToken = refreshToken
+
+	evicted, err := s.sessionRepo.Create(ctx, session, s.sessionPolicy.Limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionLimitReached) {
+			return nil, ErrSessionLimitReached
+		}
+		return nil, err
+	}
+
+	if len(evicted) > 0 {
+		evictedIDs := make([]int64, 0, len(evicted))
+		for _, old := range evicted {
+			evictedIDs = append(evictedIDs, old.ID)
+		}
+		s.revokeSessionTokens(ctx, user.ID, evictedIDs...)
+		log.Printf("[INFO] Evicted %d sessions of userID=%d to stay within the limit of %d",
+			len(evicted), user.ID, s.sessionPolicy.Limit.Max)
+	}
 
 	return &dto.AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:    int64(expiresAt.Sub(expiresAt.Add(-24 * 3600)).Seconds()),
+		TokenType:    tokenType(dpopKey),
+		ExpiresIn:    int64(time.Until(accessExpiresAt).Seconds()),
 		User:         user,
+
+		RefreshExpiresAt: session.ExpiresAt,
 	}, nil
 }
 
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, userAgent, ipAddress *string) (*dto.AuthResponse, error) {
-	_, err := s.sessionRepo.GetByRefreshToken(ctx, refreshToken)
+// tokenType is the token_type of a response, as defined by RFC 9449.
+func tokenType(dpopKey string) string {
+	if dpopKey != "" {
+		return "DPoP"
+	}
+	return "Bearer"
+}
+
+// RefreshToken rotates the session's tokens. A refresh token bound to a DPoP
+// key is only accepted together with a proof by that key, whose thumbprint
+// the caller passes as dpopKey, and the new tokens stay bound to it.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, userAgent, ipAddress *string, dpopKey string) (*dto.AuthResponse, error) {
+	current, err := s.sessionRepo.GetByRefreshToken(ctx, refreshToken)
 	if err != nil {
 		if errors.Is(err, repository.ErrSessionNotFound) {
 			return nil, errors.New("invalid refresh token")
@@ -188,6 +253,9 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, use
 		if errors.Is(err, repository.ErrSessionExpired) {
 			return nil, errors.New("refresh token expired")
 		}
+		if errors.Is(err, repository.ErrSessionIdle) {
+			return nil, errors.New("session expired due to inactivity")
+		}
 		if errors.Is(err, repository.ErrSessionRevoked) {
 			return nil, errors.New("session revoked")
 		}
@@ -199,107 +267,136 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, use
 		return nil, err
 	}
 
-	user, err := s.userRepo.GetByID(ctx, claims.UserId)
-	if err != nil {
+	boundKey := claims.KeyThumbprint()
+	if boundKey != "" && boundKey != dpopKey {
+		return nil, ErrDPoPKeyMismatch
+	}
+
+	if err := s.checkSuspension(ctx, claims.UserId); err != nil {
 		return nil, err
 	}
 
-	newAccessToken, accessExpiresAt, err := s.tokenManager.GenerateAccessToken(user.ID, user.Username, user.Email)
+	user, err := s.userRepo.GetByID(ctx, claims.UserId)
 	if err != nil {
 		return nil, err
 	}
 
-	newRefreshToken, refreshExpiresAt, err := s.tokenManager.GenerateRefreshToken(user.ID, user.Username, user.Email)
+	newAccessToken, accessExpiresAt, err := s.tokenManager.GenerateAccessToken(user.ID, user.Username, user.Email,
+		jwt.WithSessionID(current.ID), jwt.WithKeyThumbprint(boundKey))
 	if err != nil {
 		return nil, err
 	}
 
-	if err := s.sessionRepo.Revoke(ctx, refreshToken); err != nil {
+	now := time.Now()
+	expiresAt := current.SlidingExpiry(now)
+
+	newRefreshToken, _, err := s.tokenManager.GenerateRefreshToken(user.ID, user.Username, user.Email,
+		jwt.WithTTL(expiresAt.Sub(now)), jwt.WithSessionID(current.ID), jwt.WithKeyThumbprint(boundKey))
+	if err != nil {
 		return nil, err
 	}
 
-	newSession := &repository.Session{
-		UserID:       user.ID,
-		RefreshToken: newRefreshToken,
-		AccessToken:  newAccessToken,
-		UserAgent:    userAgent,
-		IPAddress:    ipAddress,
-		ExpiresAt:    refreshExpiresAt,
+	session := &repository.Session{
+		RefreshToken:  newRefreshToken,
+		AccessToken:   newAccessToken,
+		LastIPAddress: ipAddress,
+		ExpiresAt:     expiresAt,
 	}
 
-	if err := s.sessionRepo.Create(ctx, newSession); err != nil {
+	if err := s.sessionRepo.Rotate(ctx, refreshToken, session); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, errors.New("invalid refresh token")
+		}
 		return nil, err
 	}
 
 	return &dto.AuthResponse{
 		AccessToken:  newAccessToken,
 		RefreshToken: newRefreshToken,
+		TokenType:    tokenType(boundKey),
 		ExpiresIn:    int64(accessExpiresAt.Sub(time.Now()).Seconds()),
 		User:         user,
+
+		RefreshExpiresAt: session.ExpiresAt,
 	}, nil
 }
 
 func (s *AuthService) Logout(ctx context.Context, refreshToken, accessToken string) error {
-	claims, err := s.tokenManager.ValidateToken(accessToken)
-	if err == nil {
-		ttl := time.Until(claims.ExpiresAt.Time)
-		if ttl > 0 {
-			key := fmt.Sprintf("revoked:%s", accessToken)
-			_ = s.redisClient.Set(ctx, key, "revoked", ttl).Err()
-			log.Printf("[INFO] Tokens blacklisted for userID=%s (accessToken=%s..., refreshToken=%s...)",
-				claims.UserId, accessToken[:10], refreshToken[:10])
-		}
-	} else {
+	claims, err := s.tokenManager.ValidateAccessToken(accessToken)
+	if err != nil {
 		return err
 	}
-	fmt.Println("test")
 
-	return s.sessionRepo.Revoke(ctx, refreshToken)
+	session, err := s.sessionRepo.Revoke(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	s.revokeSessionTokens(ctx, claims.UserId, session.ID)
+	log.Printf("[INFO] Session %d of userID=%d logged out", session.ID, claims.UserId)
+	return nil
 }
 
+// LogoutAll revokes every session of the user. Outstanding access tokens are
+// rejected through the user's not-before time, without touching each session.
 func (s *AuthService) LogoutAll(ctx context.Context, userID int64) error {
-	sessions, err := s.sessionRepo.GetAllByUserID(ctx, userID)
-	if err != nil {
+	if err := s.sessionRepo.RevokeAllByUserID(ctx, userID); err != nil {
 		return err
 	}
 
-	for _, sess := range sessions {
-		accessToken := sess.AccessToken
-		if accessToken == "" {
-			continue
-		}
+	if err := s.revocations.RevokeUser(ctx, userID, time.Now()); err != nil {
+		log.Printf("[ERROR] Failed to revoke access tokens of userID=%d: %v", userID, err)
+	}
+	return nil
+}
 
-		claims, err := s.tokenManager.ValidateToken(accessToken)
-		if err == nil {
-			ttl := time.Until(claims.ExpiresAt.Time)
-			if ttl > 0 {
-				key := fmt.Sprintf("revoked:%s", accessToken)
-				_ = s.redisClient.Set(ctx, key, "revoked", ttl).Err()
-			}
-		}
+// RevokeSession revokes one of the user's own sessions. Sessions of other users
+// are reported as not found.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	if _, err := s.sessionRepo.RevokeByID(ctx, userID, sessionID); err != nil {
+		return err
+	}
+
+	s.revokeSessionTokens(ctx, userID, sessionID)
+	log.Printf("[INFO] Session %d revoked by userID=%d", sessionID, userID)
+	return nil
+}
+
+// LogoutOthers revokes every session of the user except the current one.
+func (s *AuthService) LogoutOthers(ctx context.Context, userID, currentSessionID int64) (int, error) {
+	if currentSessionID == 0 {
+		return 0, repository.ErrSessionNotFound
 	}
 
-	return s.sessionRepo.RevokeAllByUserID(ctx, userID)
+	revoked, err := s.sessionRepo.RevokeAllExcept(ctx, userID, currentSessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.revokeSessionTokens(ctx, userID, revoked...)
+	log.Printf("[INFO] %d other sessions revoked by userID=%d", len(revoked), userID)
+	return len(revoked), nil
+}
+
+// revokeSessionTokens rejects the outstanding access tokens of the sessions.
+// The sessions themselves are already revoked in the database, so a Redis
+// failure only leaves the access tokens usable until they expire.
+func (s *AuthService) revokeSessionTokens(ctx context.Context, userID int64, sessionIDs ...int64) {
+	if err := s.revocations.RevokeSessions(ctx, userID, sessionIDs...); err != nil {
+		log.Printf("[ERROR] Failed to revoke access tokens of sessions %v: %v", sessionIDs, err)
+	}
 }
 
-func (s *AuthService) GetActiveSessions(ctx context.Context, userID int64, currentRefreshToken string) (*models.SessionListResponse, error) {
+func (s *AuthService) GetActiveSessions(ctx context.Context, userID int64, currentRefreshToken string, currentSessionID int64) (*models.SessionListResponse, error) {
 	sessions, err := s.sessionRepo.GetAllByUserID(ctx, userID)
-	fmt.Println("check 1")
 	if err != nil {
 		return nil, err
 	}
-	fmt.Print("check 2")
 
 	sessionInfos := make([]*models.SessionInfo, 0, len(sessions))
 	for _, sess := range sessions {
-		sessionInfos = append(sessionInfos, &models.SessionInfo{
-			ID:        sess.ID,
-			UserAgent: sess.UserAgent,
-			IPAddress: sess.IPAddress,
-			CreatedAt: sess.CreatedAt,
-			ExpiresAt: sess.ExpiresAt,
-			IsCurrent: sess.RefreshToken == currentRefreshToken,
-		})
+		isCurrent := sess.RefreshToken == currentRefreshToken || sess.ID == currentSessionID
+		sessionInfos = append(sessionInfos, newSessionInfo(sess, isCurrent))
 	}
 
 	return &models.SessionListResponse{
@@ -308,6 +405,110 @@ func (s *AuthService) GetActiveSessions(ctx context.Context, userID int64, curre
 	}, nil
 }
 
+func (s *AuthService) checkSuspension(ctx context.Context, userID int64) error {
+	suspension, err := s.suspensionRepo.GetActiveByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSuspensionNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return &SuspendedError{
+		Reason: suspension.Reason,
+		EndsAt: suspension.EndsAt,
+	}
+}
+
+// SuspendUser records the suspension, flags the user in Redis so that
+// AuthMiddleware rejects their access tokens, and revokes every session.
+func (s *AuthService) SuspendUser(ctx context.Context, suspension *models.Suspension) error {
+	if err := s.suspensionRepo.Create(ctx, suspension); err != nil {
+		return err
+	}
+
+	active, err := s.suspensionRepo.GetActiveByUserID(ctx, suspension.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.revocations.Suspend(ctx, active.UserID, active.EndsAt); err != nil {
+		return err
+	}
+
+	return s.LogoutAll(ctx, suspension.UserID)
+}
+
+func (s *AuthService) LiftSuspension(ctx context.Context, userID int64, liftedBy *int64) error {
+	if err := s.suspensionRepo.LiftActive(ctx, userID, liftedBy); err != nil {
+		return err
+	}
+
+	return s.revocations.Unsuspend(ctx, userID)
+}
+
+// IssueImpersonationToken returns a short-lived access token for the target
+// user with an "act" claim naming the admin. Admins cannot be impersonated,
+// and neither can suspended users.
+func (s *AuthService) IssueImpersonationToken(ctx context.Context, userID, actorID int64, ttl time.Duration) (*dto.ImpersonationResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsAdmin() || user.ID == actorID {
+		return nil, ErrCannotImpersonate
+	}
+
+	if err := s.checkSuspension(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := s.tokenManager.GenerateAccessToken(user.ID, user.Username, user.Email,
+		jwt.WithActor(actorID), jwt.WithTTL(ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ImpersonationResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		ActorID:     actorID,
+		User:        user,
+	}, nil
+}
+
+// SendVerificationEmail stores a new verification token for the user and
+// emails it. A delivery failure is reported as ErrEmailNotSent; the token is
+// kept, so the user can still be verified by an admin or a resend.
+func (s *AuthService) SendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.generateVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	ev := &models.EmailVerification{
+		UserID:    user.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(time.Hour * 24),
+	}
+	if err := s.emailRepo.Create(ctx, ev); err != nil {
+		return err
+	}
+
+	if err := s.emailSender.SendVerificationEmail(user.Email, user.Username, token); err != nil {
+		return fmt.Errorf("%w: %v", ErrEmailNotSent, err)
+	}
+	return nil
+}
+
+func (s *AuthService) SendWelcomeEmail(user *models.User) error {
+	if err := s.emailSender.SendWelcomeEmail(user.Email, user.Username); err != nil {
+		return fmt.Errorf("%w: %v", ErrEmailNotSent, err)
+	}
+	return nil
+}
+
 func (s *AuthService) generateVerificationToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
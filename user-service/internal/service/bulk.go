@@ -0,0 +1,485 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultImportBatchSize = 1000
+	exportPageSize         = 500
+	maxImportLineBytes     = 1 << 20
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported format, expected csv or jsonl")
+	ErrInvalidImportFile = errors.New("invalid import file")
+)
+
+type ImportOptions struct {
+	Format    string
+	Emails    string
+	DryRun    bool
+	BatchSize int
+}
+
+// ImportUsers reads accounts from r and inserts them in batches. Rows that fail
+// validation or clash with existing accounts are skipped and listed in the
+// report; the remaining rows are still imported.
+func (s *AdminService) ImportUsers(ctx context.Context, actor Actor, r io.Reader, opts ImportOptions) (*dto.ImportReport, error) {
+	rows, err := newImportReader(r, opts.Format)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedFormat) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	imp := &importer{
+		admin:         s,
+		opts:          opts,
+		report:        &dto.ImportReport{DryRun: opts.DryRun, Errors: []*dto.ImportRowError{}},
+		seenUsernames: make(map[string]bool),
+		seenEmails:    make(map[string]bool),
+	}
+
+	for {
+		line, row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var rowErr *importRowError
+			if !errors.As(err, &rowErr) {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidImportFile, line, err)
+			}
+			imp.report.Total++
+			imp.fail(line, row, rowErr.err)
+			continue
+		}
+
+		imp.report.Total++
+		imp.add(line, row)
+
+		if len(imp.batch) >= batchSize {
+			if err := imp.flush(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := imp.flush(ctx); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, actor, models.AuditActionBulkImport, 0, map[string]any{
+		"format":   opts.Format,
+		"total":    imp.report.Total,
+		"imported": imp.report.Imported,
+		"failed":   imp.report.Failed,
+		"dry_run":  opts.DryRun,
+	})
+	return imp.report, nil
+}
+
+type pendingUser struct {
+	line int
+	user *models.User
+}
+
+type importer struct {
+	admin  *AdminService
+	opts   ImportOptions
+	report *dto.ImportReport
+	batch  []pendingUser
+
+	seenUsernames map[string]bool
+	seenEmails    map[string]bool
+}
+
+func (imp *importer) fail(line int, row *dto.ImportUserRow, err error) {
+	rowErr := &dto.ImportRowError{Line: line, Error: err.Error()}
+	if row != nil {
+		rowErr.Username = row.Username
+		rowErr.Email = row.Email
+	}
+	imp.report.Errors = append(imp.report.Errors, rowErr)
+	imp.report.Failed++
+}
+
+func (imp *importer) add(line int, row *dto.ImportUserRow) {
+	if err := binding.Validator.ValidateStruct(row); err != nil {
+		imp.fail(line, row, err)
+		return
+	}
+
+	if imp.seenUsernames[row.Username] {
+		imp.fail(line, row, errors.New("duplicate username in file"))
+		return
+	}
+	if imp.seenEmails[row.Email] {
+		imp.fail(line, row, errors.New("duplicate email in file"))
+		return
+	}
+
+	passwordHash := row.PasswordHash
+	if passwordHash != "" {
+		if _, err := bcrypt.Cost([]byte(passwordHash)); err != nil {
+			imp.fail(line, row, errors.New("password_hash is not a bcrypt hash"))
+			return
+		}
+	} else {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(row.Password), bcrypt.DefaultCost)
+		if err != nil {
+			imp.fail(line, row, err)
+			return
+		}
+		passwordHash = string(hashed)
+	}
+
+	imp.seenUsernames[row.Username] = true
+	imp.seenEmails[row.Email] = true
+
+	user := &models.User{
+		Username:     row.Username,
+		Email:        row.Email,
+		PasswordHash: passwordHash,
+		IsVerified:   row.Verified,
+		CreatedAt:    time.Now(),
+	}
+	if row.DisplayName != "" {
+		user.DisplayName = &row.DisplayName
+	}
+	if row.CreatedAt != nil {
+		user.CreatedAt = *row.CreatedAt
+	}
+
+	imp.batch = append(imp.batch, pendingUser{line: line, user: user})
+}
+
+func (imp *importer) flush(ctx context.Context) error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+	batch := imp.batch
+	imp.batch = nil
+
+	usernames := make([]string, 0, len(batch))
+	emails := make([]string, 0, len(batch))
+	for _, p := range batch {
+		usernames = append(usernames, p.user.Username)
+		emails = append(emails, p.user.Email)
+	}
+
+	takenUsernames, takenEmails, err := imp.admin.userRepo.FindTaken(ctx, usernames, emails)
+	if err != nil {
+		return err
+	}
+
+	users := make([]*models.User, 0, len(batch))
+	accepted := batch[:0]
+	for _, p := range batch {
+		row := &dto.ImportUserRow{Username: p.user.Username, Email: p.user.Email}
+		switch {
+		case takenUsernames[p.user.Username]:
+			imp.fail(p.line, row, errors.New("username already taken"))
+		case takenEmails[p.user.Email]:
+			imp.fail(p.line, row, errors.New("email already taken"))
+		default:
+			users = append(users, p.user)
+			accepted = append(accepted, p)
+		}
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	if imp.opts.DryRun {
+		imp.report.Imported += len(users)
+		return nil
+	}
+
+	// Another request may have claimed a username since FindTaken; in that
+	// case the whole batch is rejected rather than partially written.
+	if err := imp.admin.userRepo.CopyFrom(ctx, users); err != nil {
+		for _, p := range accepted {
+			imp.fail(p.line, &dto.ImportUserRow{Username: p.user.Username, Email: p.user.Email},
+				fmt.Errorf("batch insert failed: %v", err))
+		}
+		return nil
+	}
+	imp.report.Imported += len(users)
+
+	imp.sendEmails(ctx, users)
+	return nil
+}
+
+func (imp *importer) sendEmails(ctx context.Context, users []*models.User) {
+	for _, user := range users {
+		var err error
+		switch imp.opts.Emails {
+		case dto.ImportEmailVerification:
+			if user.IsVerified {
+				continue
+			}
+			err = imp.admin.authService.SendVerificationEmail(ctx, user)
+		case dto.ImportEmailWelcome:
+			err = imp.admin.authService.SendWelcomeEmail(user)
+		default:
+			return
+		}
+
+		if err != nil {
+			imp.report.EmailsFailed++
+			continue
+		}
+		imp.report.EmailsSent++
+	}
+}
+
+// importRowError marks a row that could not be decoded; reading can go on.
+type importRowError struct {
+	err error
+}
+
+func (e *importRowError) Error() string {
+	return e.err.Error()
+}
+
+type importReader interface {
+	// next returns io.EOF at the end of input, an *importRowError for a bad
+	// row, or any other error if the input cannot be read any further.
+	next() (int, *dto.ImportUserRow, error)
+}
+
+func newImportReader(r io.Reader, format string) (importReader, error) {
+	switch format {
+	case dto.BulkFormatCSV, "":
+		return newCSVImportReader(r)
+	case dto.BulkFormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxImportLineBytes)
+		return &jsonlImportReader{scanner: scanner}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"username", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header is missing the %q column", required)
+		}
+	}
+
+	return &csvImportReader{reader: reader, columns: columns, line: 1}, nil
+}
+
+func (r *csvImportReader) next() (int, *dto.ImportUserRow, error) {
+	record, err := r.reader.Read()
+	r.line++
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return r.line, nil, &importRowError{parseErr.Err}
+		}
+		return r.line, nil, err
+	}
+
+	field := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := &dto.ImportUserRow{
+		Username:     field("username"),
+		Email:        field("email"),
+		Password:     field("password"),
+		PasswordHash: field("password_hash"),
+		DisplayName:  field("display_name"),
+	}
+
+	if v := field("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			return r.line, row, &importRowError{fmt.Errorf("invalid verified value %q", v)}
+		}
+		row.Verified = verified
+	}
+	if v := field("created_at"); v != "" {
+		createdAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return r.line, row, &importRowError{fmt.Errorf("invalid created_at value %q", v)}
+		}
+		row.CreatedAt = &createdAt
+	}
+
+	return r.line, row, nil
+}
+
+type jsonlImportReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlImportReader) next() (int, *dto.ImportUserRow, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := &dto.ImportUserRow{}
+		if err := json.Unmarshal([]byte(text), row); err != nil {
+			return r.line, nil, &importRowError{fmt.Errorf("invalid json: %v", err)}
+		}
+		return r.line, row, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return r.line, nil, err
+	}
+	return r.line, nil, io.EOF
+}
+
+// ExportUsers streams users to w page by page, newest first, flushing after
+// every page when w supports it.
+func (s *AdminService) ExportUsers(ctx context.Context, actor Actor, w io.Writer, query *dto.AdminExportUsersQuery) error {
+	var writeRow func(row *dto.ExportUserRow) error
+	var flush func() error
+
+	switch query.Format {
+	case dto.BulkFormatCSV, "":
+		cw := csv.NewWriter(w)
+		header := []string{"id", "username", "email", "display_name", "role", "verified", "created_at"}
+		if query.IncludePasswordHash {
+			header = append(header, "password_hash")
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+
+		writeRow = func(row *dto.ExportUserRow) error {
+			record := []string{
+				strconv.FormatInt(row.ID, 10),
+				row.Username,
+				row.Email,
+				row.DisplayName,
+				row.Role,
+				strconv.FormatBool(row.Verified),
+				row.CreatedAt.Format(time.RFC3339),
+			}
+			if query.IncludePasswordHash {
+				record = append(record, row.PasswordHash)
+			}
+			return cw.Write(record)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case dto.BulkFormatJSONL:
+		enc := json.NewEncoder(w)
+		writeRow = func(row *dto.ExportUserRow) error {
+			return enc.Encode(row)
+		}
+		flush = func() error { return nil }
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	exported := 0
+	var afterID int64
+	for {
+		users, err := s.userRepo.List(ctx, repository.UserFilter{
+			State:   query.Status,
+			AfterID: afterID,
+			Limit:   exportPageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			row := &dto.ExportUserRow{
+				ID:        user.ID,
+				Username:  user.Username,
+				Email:     user.Email,
+				Role:      user.Role,
+				Verified:  user.IsVerified,
+				CreatedAt: user.CreatedAt,
+			}
+			if user.DisplayName != nil {
+				row.DisplayName = *user.DisplayName
+			}
+			if query.IncludePasswordHash {
+				row.PasswordHash = user.PasswordHash
+			}
+			if err := writeRow(row); err != nil {
+				return err
+			}
+		}
+		exported += len(users)
+
+		if err := flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+
+		if len(users) < exportPageSize {
+			break
+		}
+		afterID = users[len(users)-1].ID
+	}
+
+	s.audit(ctx, actor, models.AuditActionBulkExport, 0, map[string]any{
+		"format":                query.Format,
+		"status":                query.Status,
+		"exported":              exported,
+		"include_password_hash": query.IncludePasswordHash,
+	})
+	return nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"github.com/mileusna/useragent"
+	"github.com/zhanserikAmangeldi/user-service/internal/geoip"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"strings"
+)
+
+const (
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeBot     = "bot"
+	DeviceTypeUnknown = "unknown"
+)
+
+// describeDevice fills in the browser, OS and device type of a new session from
+// its user agent, the name the client gave the device, if any, and the
+// approximate location of its IP address.
+func (s *AuthService) describeDevice(session *repository.Session, deviceName string) {
+	if session.IPAddress != nil {
+		if location := s.geoLocator.Lookup(*session.IPAddress); location != nil {
+			if location.City != "" {
+				session.City = &location.City
+			}
+			if location.CountryCode != "" {
+				session.CountryCode = &location.CountryCode
+			}
+		}
+	}
+
+	if name := strings.TrimSpace(deviceName); name != "" {
+		session.DeviceName = &name
+	}
+
+	deviceType := DeviceTypeUnknown
+	session.DeviceType = &deviceType
+	if session.UserAgent == nil || *session.UserAgent == "" {
+		return
+	}
+
+	ua := useragent.Parse(*session.UserAgent)
+
+	switch {
+	case ua.Bot:
+		deviceType = DeviceTypeBot
+	case ua.Tablet:
+		deviceType = DeviceTypeTablet
+	case ua.Mobile:
+		deviceType = DeviceTypeMobile
+	case ua.Desktop:
+		deviceType = DeviceTypeDesktop
+	}
+
+	if ua.Name != "" {
+		browser := ua.Name
+		if ua.VersionNo.Major > 0 {
+			browser = fmt.Sprintf("%s %d", ua.Name, ua.VersionNo.Major)
+		}
+		session.Browser = &browser
+	}
+	if ua.OS != "" {
+		osName := ua.OS
+		if ua.OSVersion != "" {
+			osName = ua.OS + " " + ua.OSVersion
+		}
+		session.OS = &osName
+	}
+}
+
+func newSessionInfo(session *repository.Session, isCurrent bool) *models.SessionInfo {
+	deviceType := DeviceTypeUnknown
+	if session.DeviceType != nil {
+		deviceType = *session.DeviceType
+	}
+
+	lastIP := session.LastIPAddress
+	if lastIP == nil {
+		lastIP = session.IPAddress
+	}
+
+	var location *string
+	if session.City != nil || session.CountryCode != nil {
+		loc := &geoip.Location{}
+		if session.City != nil {
+			loc.City = *session.City
+		}
+		if session.CountryCode != nil {
+			loc.CountryCode = *session.CountryCode
+		}
+		formatted := loc.String()
+		location = &formatted
+	}
+
+	return &models.SessionInfo{
+		ID:                session.ID,
+		DeviceName:        session.DeviceName,
+		DeviceType:        deviceType,
+		Browser:           session.Browser,
+		OS:                session.OS,
+		UserAgent:         session.UserAgent,
+		IPAddress:         lastIP,
+		FirstIPAddress:    session.IPAddress,
+		Location:          location,
+		CreatedAt:         session.CreatedAt,
+		LastActiveAt:      session.LastActiveAt,
+		ExpiresAt:         session.ExpiresAt,
+		AbsoluteExpiresAt: session.AbsoluteExpiresAt,
+		IsCurrent:         isCurrent,
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DeviceCodeGrantType is the grant_type of RFC 8628 token requests.
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// userCodeAlphabet has no vowels, so that codes do not spell words, and no
+// characters that are easily confused, as RFC 8628 section 6.1 suggests.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// Token endpoint errors, named after the RFC 8628 error codes.
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrDeviceCodeExpired    = errors.New("expired_token")
+	ErrInvalidDeviceCode    = errors.New("invalid_grant")
+	ErrUnsupportedGrantType = errors.New("unsupported_grant_type")
+)
+
+// ErrUserCodeNotFound is returned when approving or denying a user code that
+// is unknown, expired or already decided.
+var ErrUserCodeNotFound = errors.New("user code not found")
+
+// DeviceAuthService implements the RFC 8628 device authorization grant, which
+// lets a device without a convenient keyboard sign in by having the user
+// approve it from a browser where they are already signed in.
+type DeviceAuthService struct {
+	repo            *repository.DeviceAuthorizationRepository
+	authService     *AuthService
+	verificationURI string
+	lifetime        time.Duration
+	interval        time.Duration
+}
+
+func NewDeviceAuthService(
+	repo *repository.DeviceAuthorizationRepository,
+	authService *AuthService,
+	verificationURI string,
+	lifetime, interval time.Duration,
+) *DeviceAuthService {
+	return &DeviceAuthService{
+		repo:            repo,
+		authService:     authService,
+		verificationURI: verificationURI,
+		lifetime:        lifetime,
+		interval:        interval,
+	}
+}
+
+// StartAuthorization issues a device code for the device to poll with and a
+// user code for the user to enter on the verification page.
+func (s *DeviceAuthService) StartAuthorization(ctx context.Context, req *dto.DeviceCodeRequest, userAgent, ipAddress *string) (*dto.DeviceCodeResponse, error) {
+	deviceCode, err := newDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+
+	da := &models.DeviceAuthorization{
+		DeviceCodeHash: hashDeviceCode(deviceCode),
+		UserAgent:      userAgent,
+		IPAddress:      ipAddress,
+		Interval:       s.interval,
+		ExpiresAt:      time.Now().Add(s.lifetime),
+	}
+	if req.ClientID != "" {
+		da.ClientID = &req.ClientID
+	}
+
+	// User codes are short, so retry the rare collision with a live request.
+	for attempt := 0; ; attempt++ {
+		da.UserCode, err = newUserCode()
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.repo.Create(ctx, da)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrUserCodeTaken) || attempt == 4 {
+			return nil, err
+		}
+	}
+
+	userCode := FormatUserCode(da.UserCode)
+	return &dto.DeviceCodeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         s.verificationURI,
+		VerificationURIComplete: s.verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int64(s.lifetime.Seconds()),
+		Interval:                int64(s.interval.Seconds()),
+	}, nil
+}
+
+// GetPending describes the device behind a user code for the approval page.
+func (s *DeviceAuthService) GetPending(ctx context.Context, userCode string) (*dto.DeviceAuthorizationInfo, error) {
+	da, err := s.repo.GetPendingByUserCode(ctx, NormalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, repository.ErrDeviceAuthorizationNotFound) {
+			return nil, ErrUserCodeNotFound
+		}
+		return nil, err
+	}
+
+	device := &repository.Session{UserAgent: da.UserAgent, IPAddress: da.IPAddress}
+	s.authService.describeDevice(device, "")
+	info := newSessionInfo(device, false)
+
+	return &dto.DeviceAuthorizationInfo{
+		UserCode:   FormatUserCode(da.UserCode),
+		ClientID:   da.ClientID,
+		DeviceType: info.DeviceType,
+		Browser:    info.Browser,
+		OS:         info.OS,
+		UserAgent:  da.UserAgent,
+		IPAddress:  da.IPAddress,
+		Location:   info.Location,
+		CreatedAt:  da.CreatedAt,
+		ExpiresAt:  da.ExpiresAt,
+	}, nil
+}
+
+// Decide approves or denies the request with the given user code on behalf of
+// the signed-in user.
+func (s *DeviceAuthService) Decide(ctx context.Context, userID int64, userCode string, approve bool) error {
+	if approve {
+		if err := s.authService.checkSuspension(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.repo.Decide(ctx, NormalizeUserCode(userCode), userID, approve)
+	if errors.Is(err, repository.ErrDeviceAuthorizationNotFound) {
+		return ErrUserCodeNotFound
+	}
+	return err
+}
+
+// PollToken answers a token request from the device. Once the user has
+// approved, the first poll starts a regular session recorded with the user
+// agent and IP address the device had when it asked for a code. If no session
+// can be started the approval is kept, so that a later poll can retry.
+func (s *DeviceAuthService) PollToken(ctx context.Context, req *dto.DeviceTokenRequest, dpopKey string) (*dto.AuthResponse, error) {
+	if req.GrantType != DeviceCodeGrantType {
+		return nil, ErrUnsupportedGrantType
+	}
+
+	da, tooFast, err := s.repo.Poll(ctx, hashDeviceCode(req.DeviceCode))
+	if err != nil {
+		if errors.Is(err, repository.ErrDeviceAuthorizationNotFound) {
+			return nil, ErrInvalidDeviceCode
+		}
+		return nil, err
+	}
+
+	if da.ClientID != nil && *da.ClientID != req.ClientID {
+		return nil, ErrInvalidDeviceCode
+	}
+
+	switch da.Status {
+	case models.DeviceAuthorizationDenied:
+		return nil, ErrAccessDenied
+	case models.DeviceAuthorizationConsumed:
+		return nil, ErrInvalidDeviceCode
+	}
+	if time.Now().After(da.ExpiresAt) {
+		return nil, ErrDeviceCodeExpired
+	}
+	if da.Status == models.DeviceAuthorizationPending {
+		if tooFast {
+			return nil, ErrSlowDown
+		}
+		return nil, ErrAuthorizationPending
+	}
+
+	if err := s.repo.Consume(ctx, da.ID); err != nil {
+		if errors.Is(err, repository.ErrDeviceAuthorizationNotFound) {
+			return nil, ErrInvalidDeviceCode
+		}
+		return nil, err
+	}
+
+	authResp, err := s.signIn(ctx, da, req.DeviceName, dpopKey)
+	if err != nil {
+		if releaseErr := s.repo.Release(context.WithoutCancel(ctx), da.ID); releaseErr != nil {
+			log.Printf("[ERROR] Failed to release device authorization %d: %v", da.ID, releaseErr)
+		}
+		return nil, err
+	}
+
+	return authResp, nil
+}
+
+func (s *DeviceAuthService) signIn(ctx context.Context, da *models.DeviceAuthorization, deviceName, dpopKey string) (*dto.AuthResponse, error) {
+	user, err := s.authService.userRepo.GetByID(ctx, *da.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrAccessDenied
+		}
+		return nil, err
+	}
+	if err := s.authService.checkSuspension(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	authResp, err := s.authService.startSession(ctx, user, da.UserAgent, da.IPAddress, deviceName, dpopKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s.authService.presence.Touch(ctx, user.ID)
+
+	return authResp, nil
+}
+
+// NormalizeUserCode strips the dash and case that users may type differently.
+func NormalizeUserCode(userCode string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(userCode))
+}
+
+// FormatUserCode splits a stored user code in two halves for readability.
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+func newDeviceCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newUserCode() (string, error) {
+	b := make([]byte, userCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// 256 is not a multiple of 20, which biases the first 16 letters by under
+	// 1%; irrelevant for a code that lives for minutes.
+	for i := range b {
+		b[i] = userCodeAlphabet[int(b[i])%len(userCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+func hashDeviceCode(deviceCode string) string {
+	sum := sha256.Sum256([]byte(deviceCode))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"log"
+	"time"
+)
+
+// KeyReloadInterval is how often API instances reload the signing keys.
+const KeyReloadInterval = time.Minute
+
+// keyActivationDelay keeps a rotated-in key verify-only until every instance
+// has reloaded at least once, so no instance sees a token signed by a key it
+// does not know yet.
+const keyActivationDelay = 2 * KeyReloadInterval
+
+const signingAlgorithm = "EdDSA"
+
+type KeyService struct {
+	keyRepo         *repository.SigningKeyRepository
+	tokenManager    *jwt.TokenManager
+	retirementGrace time.Duration
+}
+
+// NewKeyService keeps rotated-out keys verifying for retirementGrace after the
+// new key activates. It must cover the longest refresh token lifetime, which
+// is the absolute session lifetime.
+func NewKeyService(keyRepo *repository.SigningKeyRepository, tokenManager *jwt.TokenManager, retirementGrace time.Duration) *KeyService {
+	return &KeyService{
+		keyRepo:         keyRepo,
+		tokenManager:    tokenManager,
+		retirementGrace: retirementGrace,
+	}
+}
+
+// Reload installs the signing keys stored in the database into the token
+// manager. With no keys stored, tokens keep being signed with JWT_SECRET.
+func (s *KeyService) Reload(ctx context.Context) error {
+	records, err := s.keyRepo.ListUsable(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var active *jwt.SigningKey
+	keys := make([]*jwt.SigningKey, 0, len(records))
+	for _, record := range records {
+		key, err := jwt.ParseSigningKey(record.KID, record.PrivateKey)
+		if err != nil {
+			log.Printf("[ERROR] Skipping unreadable signing key kid=%s: %v", record.KID, err)
+			continue
+		}
+
+		if active == nil && !record.ActivatesAt.After(now) {
+			active = key
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	s.tokenManager.SetSigningKeys(active, keys)
+	return nil
+}
+
+// Rotate generates a new signing key. Running API instances publish it on
+// their next reload and start signing with it once it activates.
+func (s *KeyService) Rotate(ctx context.Context) (*repository.SigningKey, error) {
+	key, err := jwt.GenerateSigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyPEM, err := key.PrivateKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &repository.SigningKey{
+		KID:        key.ID,
+		Algorithm:  signingAlgorithm,
+		PrivateKey: privateKeyPEM,
+	}
+	activateAt := time.Now().Add(keyActivationDelay)
+	if err := s.keyRepo.Rotate(ctx, record, activateAt, activateAt.Add(s.retirementGrace)); err != nil {
+		return nil, err
+	}
+
+	return record, s.Reload(ctx)
+}
+
+func (s *KeyService) RunReloader(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("[ERROR] Failed to reload signing keys: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"time"
+)
+
+// MaintenanceService deletes data that is no longer needed. Its methods are
+// run periodically by the scheduler in cmd/api.
+type MaintenanceService struct {
+	sessionRepo    *repository.SessionRepository
+	emailRepo      *repository.EmailVerificationRepository
+	signingKeyRepo *repository.SigningKeyRepository
+	deviceAuthRepo *repository.DeviceAuthorizationRepository
+	retention      time.Duration
+}
+
+// NewMaintenanceService returns a service that keeps expired data for
+// retention before deleting it.
+func NewMaintenanceService(
+	sessionRepo *repository.SessionRepository,
+	emailRepo *repository.EmailVerificationRepository,
+	signingKeyRepo *repository.SigningKeyRepository,
+	deviceAuthRepo *repository.DeviceAuthorizationRepository,
+	retention time.Duration,
+) *MaintenanceService {
+	return &MaintenanceService{
+		sessionRepo:    sessionRepo,
+		emailRepo:      emailRepo,
+		signingKeyRepo: signingKeyRepo,
+		deviceAuthRepo: deviceAuthRepo,
+		retention:      retention,
+	}
+}
+
+func (s *MaintenanceService) CleanupSessions(ctx context.Context) (int64, error) {
+	return s.sessionRepo.DeleteExpired(ctx, s.retention)
+}
+
+func (s *MaintenanceService) CleanupEmailVerifications(ctx context.Context) (int64, error) {
+	return s.emailRepo.DeleteExpired(ctx, s.retention)
+}
+
+func (s *MaintenanceService) CleanupSigningKeys(ctx context.Context) (int64, error) {
+	return s.signingKeyRepo.DeleteRetired(ctx, time.Now().Add(-s.retention))
+}
+
+// CleanupDeviceAuthorizations deletes device codes soon after they expire;
+// they are useless afterwards and only kept briefly for debugging.
+func (s *MaintenanceService) CleanupDeviceAuthorizations(ctx context.Context) (int64, error) {
+	return s.deviceAuthRepo.DeleteExpired(ctx, time.Hour)
+}
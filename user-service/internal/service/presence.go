@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/presence"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"log"
+	"time"
+)
+
+const sweepBatchSize = 1000
+
+// PresenceService derives users' status from the heartbeats of their
+// connections, layers their manual override on top and announces changes
+// to presence watchers.
+//
+// Heartbeats only touch Redis. last_seen_at is written back to Postgres when
+// the user is settled outside the heartbeat path: on disconnect, when a
+// connection expires and when they go away.
+type PresenceService struct {
+	userRepo *repository.UserRepository
+	tracker  *presence.Tracker
+	hub      *presence.Hub
+}
+
+func NewPresenceService(userRepo *repository.UserRepository, tracker *presence.Tracker, hub *presence.Hub) *PresenceService {
+	return &PresenceService{userRepo: userRepo, tracker: tracker, hub: hub}
+}
+
+// HeartbeatInterval is how often clients should send heartbeats to stay
+// connected.
+func (s *PresenceService) HeartbeatInterval() time.Duration {
+	return s.tracker.TTL() / 2
+}
+
+// Heartbeat keeps a connection of the user up and returns their status as
+// they see it themselves.
+func (s *PresenceService) Heartbeat(ctx context.Context, userID int64, connectionID string, active bool) (string, error) {
+	state, err := s.tracker.Heartbeat(ctx, userID, connectionID, active)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	s.settle(ctx, user, state, false)
+	return selfStatus(s.tracker.Status(state, user.PresenceOverride), user, state), nil
+}
+
+// Disconnect takes a connection of the user down without waiting for it to
+// expire.
+func (s *PresenceService) Disconnect(ctx context.Context, userID int64, connectionID string) error {
+	if err := s.tracker.Disconnect(ctx, userID, connectionID); err != nil {
+		return err
+	}
+	return s.Refresh(ctx, userID)
+}
+
+// Touch records activity that does not come with a connection, such as a
+// login. A nil PresenceService does nothing.
+func (s *PresenceService) Touch(ctx context.Context, userID int64) {
+	if s == nil {
+		return
+	}
+	if err := s.tracker.Touch(ctx, userID); err != nil {
+		log.Printf("[WARN] Failed to record activity of userID=%d: %v", userID, err)
+	}
+}
+
+// Refresh re-evaluates the user's status, for example after they changed
+// their override, and writes last_seen_at back.
+func (s *PresenceService) Refresh(ctx context.Context, userID int64) error {
+	state, err := s.tracker.State(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return s.tracker.Schedule(ctx, userID, time.Time{})
+		}
+		return err
+	}
+
+	s.settle(ctx, user, state, true)
+	return nil
+}
+
+// Sweep settles users whose status may have changed without a heartbeat:
+// their last connection expired or they went away. Every replica may sweep;
+// each due user is handled by one of them.
+func (s *PresenceService) Sweep(ctx context.Context) (int64, error) {
+	userIDs, err := s.tracker.Due(ctx, sweepBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var settled int64
+	for _, userID := range userIDs {
+		claimed, err := s.tracker.Claim(ctx, userID)
+		if err != nil {
+			return settled, err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.Refresh(ctx, userID); err != nil {
+			log.Printf("[WARN] Failed to settle presence of userID=%d: %v", userID, err)
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// RunSweeper sweeps every interval until ctx is cancelled.
+func (s *PresenceService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[ERROR] Presence sweep failed: %v", err)
+			}
+		}
+	}
+}
+
+// Resolve fills in the status and last-seen time of user as the viewer sees
+// them. Others see an invisible user as offline, with the last-seen time of
+// their last disconnect, and never see the override itself. If Redis cannot
+// be reached the user is shown as stored.
+func (s *PresenceService) Resolve(ctx context.Context, user *models.User, self bool) {
+	state, err := s.tracker.State(ctx, user.ID)
+	if err != nil {
+		log.Printf("[WARN] Failed to read presence of userID=%d: %v", user.ID, err)
+		state = &presence.State{}
+	}
+
+	status := s.tracker.Status(state, user.PresenceOverride)
+	if self {
+		user.Status = selfStatus(status, user, state)
+	} else {
+		user.Status = status
+	}
+	if self || !isInvisible(user) {
+		user.LastSeenAt = latest(user.LastSeenAt, state.LastActiveAt)
+	}
+	if !self {
+		user.PresenceOverride = nil
+	}
+}
+
+// Subscribe watches the announced status changes of userIDs.
+func (s *PresenceService) Subscribe(ctx context.Context, userIDs []int64) (*presence.Subscription, error) {
+	return s.hub.Subscribe(ctx, userIDs)
+}
+
+// settle announces the user's status if it changed and schedules the next
+// time it may change on its own. With writeBack the last activity is
+// written to last_seen_at.
+func (s *PresenceService) settle(ctx context.Context, user *models.User, state *presence.State, writeBack bool) {
+	status := s.tracker.Status(state, user.PresenceOverride)
+
+	if err := s.tracker.Schedule(ctx, user.ID, s.tracker.NextChange(state)); err != nil {
+		log.Printf("[WARN] Failed to schedule presence of userID=%d: %v", user.ID, err)
+	}
+
+	lastSeenAt := user.LastSeenAt
+	if writeBack && state.LastActiveAt != nil && (lastSeenAt == nil || state.LastActiveAt.After(*lastSeenAt)) {
+		if err := s.userRepo.UpdateLastSeen(ctx, user.ID, *state.LastActiveAt); err != nil {
+			log.Printf("[WARN] Failed to write back last_seen_at of userID=%d: %v", user.ID, err)
+		}
+	}
+	if !isInvisible(user) {
+		lastSeenAt = latest(lastSeenAt, state.LastActiveAt)
+	}
+
+	changed, err := s.tracker.SwapStatus(ctx, user.ID, status)
+	if err != nil {
+		log.Printf("[WARN] Failed to record presence of userID=%d: %v", user.ID, err)
+		return
+	}
+	if changed {
+		s.hub.Publish(ctx, presence.Update{UserID: user.ID, Status: status, LastSeenAt: lastSeenAt})
+	}
+}
+
+// selfStatus shows invisible users that they are connected but hidden.
+func selfStatus(status string, user *models.User, state *presence.State) string {
+	if state.Connected && isInvisible(user) {
+		return presence.StatusInvisible
+	}
+	return status
+}
+
+func isInvisible(user *models.User) bool {
+	return user.PresenceOverride != nil && *user.PresenceOverride == models.PresenceInvisible
+}
+
+func latest(stored, active *time.Time) *time.Time {
+	if active != nil && (stored == nil || active.After(*stored)) {
+		return active
+	}
+	return stored
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	qrLoginPending  = "pending"
+	qrLoginApproved = "approved"
+	qrLoginDenied   = "denied"
+)
+
+var (
+	ErrQRLoginTicketNotFound = errors.New("login ticket not found or expired")
+	ErrQRLoginPending        = errors.New("login ticket not approved yet")
+	ErrQRLoginDenied         = errors.New("login ticket denied")
+)
+
+// decideQRLoginScript sets the outcome of a pending ticket and wakes up the
+// web client waiting for it. It returns 0 if the ticket is gone and -1 if it
+// was already decided.
+var decideQRLoginScript = redis.NewScript(`
+local status = redis.call("HGET", KEYS[1], "status")
+if not status then
+	return 0
+end
+if status ~= "pending" then
+	return -1
+end
+redis.call("HSET", KEYS[1], "status", ARGV[1], "user_id", ARGV[2])
+redis.call("PUBLISH", KEYS[2], ARGV[1])
+return 1
+`)
+
+// takeQRLoginScript returns the ticket if the poll token matches. A denied
+// ticket is deleted and an approved one is marked as consuming, so that only
+// one poll signs in with it.
+var takeQRLoginScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "poll_token_hash") ~= ARGV[1] then
+	return false
+end
+local ticket = redis.call("HGETALL", KEYS[1])
+local status = redis.call("HGET", KEYS[1], "status")
+if status == "denied" then
+	redis.call("DEL", KEYS[1])
+elseif status == "approved" then
+	redis.call("HSET", KEYS[1], "status", "consuming")
+end
+return ticket
+`)
+
+// releaseQRLoginScript returns a consuming ticket to approved when signing in
+// with it failed, and wakes up the polls waiting for it.
+var releaseQRLoginScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "status") ~= "consuming" then
+	return 0
+end
+redis.call("HSET", KEYS[1], "status", "approved")
+redis.call("PUBLISH", KEYS[2], "approved")
+return 1
+`)
+
+func qrLoginKey(ticketID string) string {
+	return "qr_login:" + ticketID
+}
+
+func qrLoginChannel(ticketID string) string {
+	return "qr_login_decided:" + ticketID
+}
+
+// QRLoginService signs a web client in by having a signed-in mobile app
+// approve a ticket shown as a QR code. Tickets live in Redis as hashes under
+// qr_login:<ticket id> until they expire or are used.
+type QRLoginService struct {
+	redis       redis.UniversalClient
+	authService *AuthService
+	ttl         time.Duration
+	pollTimeout time.Duration
+}
+
+func NewQRLoginService(redisClient redis.UniversalClient, authService *AuthService, ttl, pollTimeout time.Duration) *QRLoginService {
+	return &QRLoginService{
+		redis:       redisClient,
+		authService: authService,
+		ttl:         ttl,
+		pollTimeout: pollTimeout,
+	}
+}
+
+// CreateTicket starts a login for the web client making the request, whose
+// user agent and IP address the resulting session will be recorded with.
+func (s *QRLoginService) CreateTicket(ctx context.Context, userAgent, ipAddress *string) (*dto.QRLoginTicketResponse, error) {
+	ticketID, err := newQRLoginSecret()
+	if err != nil {
+		return nil, err
+	}
+	pollToken, err := newQRLoginSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]any{
+		"status":          qrLoginPending,
+		"poll_token_hash": hashPollToken(pollToken),
+		"created_at":      time.Now().Unix(),
+	}
+	if userAgent != nil {
+		fields["user_agent"] = *userAgent
+	}
+	if ipAddress != nil {
+		fields["ip_address"] = *ipAddress
+	}
+
+	key := qrLoginKey(ticketID)
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fields)
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.QRLoginTicketResponse{
+		TicketID:  ticketID,
+		PollToken: pollToken,
+		ExpiresIn: int64(s.ttl.Seconds()),
+	}, nil
+}
+
+// GetTicket describes a pending ticket to the mobile app before it approves.
+func (s *QRLoginService) GetTicket(ctx context.Context, ticketID string) (*dto.QRLoginTicketInfo, error) {
+	ticket, err := s.redis.HGetAll(ctx, qrLoginKey(ticketID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ticket["status"] != qrLoginPending {
+		return nil, ErrQRLoginTicketNotFound
+	}
+
+	device := ticketSession(ticket)
+	s.authService.describeDevice(device, "")
+	info := newSessionInfo(device, false)
+
+	createdAt, _ := strconv.ParseInt(ticket["created_at"], 10, 64)
+	return &dto.QRLoginTicketInfo{
+		DeviceType: info.DeviceType,
+		Browser:    info.Browser,
+		OS:         info.OS,
+		IPAddress:  device.IPAddress,
+		Location:   info.Location,
+		CreatedAt:  time.Unix(createdAt, 0),
+	}, nil
+}
+
+// Decide approves or denies a pending ticket on behalf of the signed-in user.
+func (s *QRLoginService) Decide(ctx context.Context, userID int64, ticketID string, approve bool) error {
+	status := qrLoginDenied
+	if approve {
+		if err := s.authService.checkSuspension(ctx, userID); err != nil {
+			return err
+		}
+		status = qrLoginApproved
+	}
+
+	result, err := decideQRLoginScript.Run(ctx, s.redis,
+		[]string{qrLoginKey(ticketID), qrLoginChannel(ticketID)},
+		status, userID,
+	).Int()
+	if err != nil {
+		return err
+	}
+	if result != 1 {
+		return ErrQRLoginTicketNotFound
+	}
+
+	return nil
+}
+
+// Poll waits up to the poll timeout for the ticket to be decided. Once it has
+// been approved, the first poll signs the web client in and the ticket is gone.
+// If signing in fails the ticket stays approved, so that a later poll can
+// retry without another approval.
+func (s *QRLoginService) Poll(ctx context.Context, req *dto.QRLoginPollRequest, dpopKey string) (*dto.AuthResponse, error) {
+	// Subscribe before the first look at the ticket, so that a decision made
+	// in between is not missed.
+	sub := s.redis.Subscribe(ctx, qrLoginChannel(req.TicketID))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(s.pollTimeout)
+	defer timer.Stop()
+
+	for {
+		ticket, err := s.takeTicket(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch ticket["status"] {
+		case qrLoginApproved:
+			return s.consumeTicket(ctx, req.TicketID, ticket, dpopKey)
+		case qrLoginDenied:
+			return nil, ErrQRLoginDenied
+		}
+
+		select {
+		case <-sub.Channel():
+		case <-timer.C:
+			return nil, ErrQRLoginPending
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *QRLoginService) takeTicket(ctx context.Context, req *dto.QRLoginPollRequest) (map[string]string, error) {
+	values, err := takeQRLoginScript.Run(ctx, s.redis, []string{qrLoginKey(req.TicketID)}, hashPollToken(req.PollToken)).StringSlice()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrQRLoginTicketNotFound
+		}
+		return nil, err
+	}
+
+	ticket := make(map[string]string, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		ticket[values[i]] = values[i+1]
+	}
+	return ticket, nil
+}
+
+// consumeTicket signs in with a ticket taken by this poll, deleting it on
+// success and releasing it otherwise.
+func (s *QRLoginService) consumeTicket(ctx context.Context, ticketID string, ticket map[string]string, dpopKey string) (*dto.AuthResponse, error) {
+	keys := []string{qrLoginKey(ticketID), qrLoginChannel(ticketID)}
+
+	authResp, err := s.signIn(ctx, ticket, dpopKey)
+	if err != nil {
+		if releaseErr := releaseQRLoginScript.Run(context.WithoutCancel(ctx), s.redis, keys).Err(); releaseErr != nil {
+			log.Printf("[ERROR] Failed to release login ticket: %v", releaseErr)
+		}
+		return nil, err
+	}
+
+	// A ticket left behind is still consuming and cannot be used again.
+	if err := s.redis.Del(context.WithoutCancel(ctx), keys[0]).Err(); err != nil {
+		log.Printf("[WARN] Failed to delete used login ticket: %v", err)
+	}
+	return authResp, nil
+}
+
+func (s *QRLoginService) signIn(ctx context.Context, ticket map[string]string, dpopKey string) (*dto.AuthResponse, error) {
+	userID, err := strconv.ParseInt(ticket["user_id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("approved login ticket without user: %w", err)
+	}
+
+	user, err := s.authService.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrQRLoginDenied
+		}
+		return nil, err
+	}
+	if err := s.authService.checkSuspension(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	device := ticketSession(ticket)
+	authResp, err := s.authService.startSession(ctx, user, device.UserAgent, device.IPAddress, "", dpopKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s.authService.presence.Touch(ctx, user.ID)
+
+	return authResp, nil
+}
+
+// ticketSession returns a session describing the web client of a ticket.
+func ticketSession(ticket map[string]string) *repository.Session {
+	session := &repository.Session{}
+	if userAgent, ok := ticket["user_agent"]; ok {
+		session.UserAgent = &userAgent
+	}
+	if ip, ok := ticket["ip_address"]; ok {
+		session.IPAddress = &ip
+	}
+	return session
+}
+
+func newQRLoginSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashPollToken(pollToken string) string {
+	sum := sha256.Sum256([]byte(pollToken))
+	return hex.EncodeToString(sum[:])
+}
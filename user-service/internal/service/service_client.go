@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/internal/models"
+	"github.com/zhanserikAmangeldi/user-service/internal/repository"
+	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ClientCredentialsGrantType is the grant_type of RFC 6749 section 4.4 token requests.
+const ClientCredentialsGrantType = "client_credentials"
+
+// Token endpoint errors, named after the RFC 6749 error codes.
+var (
+	ErrInvalidClient = errors.New("invalid_client")
+	ErrInvalidScope  = errors.New("invalid_scope")
+)
+
+// ServiceClientService registers other services and issues them service
+// tokens with the client credentials grant.
+type ServiceClientService struct {
+	repo         *repository.ServiceClientRepository
+	tokenManager *jwt.TokenManager
+	tokenTTL     time.Duration
+}
+
+func NewServiceClientService(repo *repository.ServiceClientRepository, tokenManager *jwt.TokenManager, tokenTTL time.Duration) *ServiceClientService {
+	return &ServiceClientService{
+		repo:         repo,
+		tokenManager: tokenManager,
+		tokenTTL:     tokenTTL,
+	}
+}
+
+// Register creates a service client. A secret is generated unless the client
+// authenticates with a client certificate only; it is returned once and
+// stored hashed.
+func (s *ServiceClientService) Register(ctx context.Context, clientID, name string, scopes []string, tlsIdentity string, withSecret bool) (*models.ServiceClient, string, error) {
+	for _, scope := range scopes {
+		if !slices.Contains(models.ServiceScopes, scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidScope, scope)
+		}
+	}
+	if !withSecret && tlsIdentity == "" {
+		return nil, "", errors.New("a service client needs a secret or a TLS identity")
+	}
+
+	client := &models.ServiceClient{
+		ClientID: clientID,
+		Name:     name,
+		Scopes:   scopes,
+	}
+	if tlsIdentity != "" {
+		client.TLSIdentity = &tlsIdentity
+	}
+
+	var secret string
+	if withSecret {
+		var err error
+		secret, err = newClientSecret()
+		if err != nil {
+			return nil, "", err
+		}
+		hash := hashClientSecret(secret)
+		client.SecretHash = &hash
+	}
+
+	if err := s.repo.Create(ctx, client); err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+// RotateSecret replaces the secret of a client. The old secret stops working
+// at once; service tokens already issued stay valid until they expire.
+func (s *ServiceClientService) RotateSecret(ctx context.Context, clientID string) (string, error) {
+	secret, err := newClientSecret()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.repo.SetSecretHash(ctx, clientID, hashClientSecret(secret)); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (s *ServiceClientService) Disable(ctx context.Context, clientID string) error {
+	return s.repo.Disable(ctx, clientID)
+}
+
+func (s *ServiceClientService) List(ctx context.Context) ([]*models.ServiceClient, error) {
+	return s.repo.List(ctx)
+}
+
+// IssueToken handles a client credentials token request. The client
+// authenticates with its secret or, when it sent none, with tlsIdentity, the
+// identity of its verified client certificate. Without a scope parameter the
+// token gets every scope registered for the client.
+func (s *ServiceClientService) IssueToken(ctx context.Context, req *dto.ClientCredentialsRequest, tlsIdentity string) (*dto.ServiceTokenResponse, error) {
+	if req.GrantType != ClientCredentialsGrantType {
+		return nil, ErrUnsupportedGrantType
+	}
+
+	client, err := s.authenticate(ctx, req, tlsIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := client.Scopes
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		for _, scope := range requested {
+			if !slices.Contains(client.Scopes, scope) {
+				return nil, ErrInvalidScope
+			}
+		}
+		scopes = requested
+	}
+
+	accessToken, expiresAt, err := s.tokenManager.GenerateServiceToken(client.ClientID, scopes, jwt.WithTTL(s.tokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ServiceTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+func (s *ServiceClientService) authenticate(ctx context.Context, req *dto.ClientCredentialsRequest, tlsIdentity string) (*models.ServiceClient, error) {
+	var (
+		client *models.ServiceClient
+		err    error
+	)
+
+	switch {
+	case req.ClientSecret != "":
+		client, err = s.repo.GetByClientID(ctx, req.ClientID)
+		if err == nil && (client.SecretHash == nil ||
+			subtle.ConstantTimeCompare([]byte(*client.SecretHash), []byte(hashClientSecret(req.ClientSecret))) != 1) {
+			return nil, ErrInvalidClient
+		}
+	case tlsIdentity != "":
+		client, err = s.repo.GetByTLSIdentity(ctx, tlsIdentity)
+		if err == nil && req.ClientID != "" && req.ClientID != client.ClientID {
+			return nil, ErrInvalidClient
+		}
+	default:
+		return nil, ErrInvalidClient
+	}
+
+	if err != nil {
+		if errors.Is(err, repository.ErrServiceClientNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	if client.IsDisabled() {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+func newClientSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
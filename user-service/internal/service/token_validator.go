@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
+)
+
+var (
+	ErrTokenRevoked      = errors.New("token revoked")
+	ErrUserTokenRequired = errors.New("service tokens are not accepted here")
+)
+
+// TokenValidator validates user access tokens the way AuthMiddleware does,
+// for callers that are handed a token rather than a request. Revocation is
+// not enforced when the revocation store is unreachable. A suspended user's
+// token fails with a *SuspendedError.
+type TokenValidator struct {
+	tokenManager *jwt.TokenManager
+	revocations  *revocation.Checker
+}
+
+func NewTokenValidator(tokenManager *jwt.TokenManager, revocations *revocation.Checker) *TokenValidator {
+	return &TokenValidator{tokenManager: tokenManager, revocations: revocations}
+}
+
+func (v *TokenValidator) Validate(ctx context.Context, token string) (*jwt.Claims, error) {
+	claims, err := v.tokenManager.ValidateAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IsService() {
+		return nil, ErrUserTokenRequired
+	}
+
+	status, err := v.revocations.Check(ctx, claims.UserId, claims.SessionID, claims.IssuedAt.Time)
+	if err != nil {
+		return claims, nil
+	}
+	if status.Suspended {
+		return nil, &SuspendedError{EndsAt: status.SuspendedUntil}
+	}
+	if status.Revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
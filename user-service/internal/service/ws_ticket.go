@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/zhanserikAmangeldi/user-service/internal/dto"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
+	"log"
+	"time"
+)
+
+var (
+	ErrInvalidWSTicket = errors.New("invalid or expired websocket ticket")
+	ErrWSTicketRevoked = errors.New("session of the websocket ticket has been revoked")
+)
+
+// wsTicketKey stores tickets under a hash, so that Redis never holds a ticket
+// that could still be redeemed.
+func wsTicketKey(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return "ws_ticket:" + hex.EncodeToString(sum[:])
+}
+
+// WSTicketService issues the opaque tickets browsers pass to the chat gateway
+// when opening a WebSocket, instead of putting an access token in the URL.
+// A ticket is redeemable once, within ttl of being issued.
+type WSTicketService struct {
+	redis       redis.UniversalClient
+	revocations *revocation.Checker
+	ttl         time.Duration
+}
+
+func NewWSTicketService(redisClient redis.UniversalClient, revocations *revocation.Checker, ttl time.Duration) *WSTicketService {
+	return &WSTicketService{redis: redisClient, revocations: revocations, ttl: ttl}
+}
+
+// Issue returns a ticket bound to the user and the session of their access token.
+func (s *WSTicketService) Issue(ctx context.Context, userID, sessionID int64, username string) (*dto.WSTicketResponse, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+
+	data, err := json.Marshal(&dto.WSTicketValidation{
+		UserID:    userID,
+		SessionID: sessionID,
+		Username:  username,
+		IssuedAt:  time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redis.Set(ctx, wsTicketKey(ticket), data, s.ttl).Err(); err != nil {
+		return nil, err
+	}
+
+	return &dto.WSTicketResponse{
+		Ticket:    ticket,
+		ExpiresIn: int64(s.ttl.Seconds()),
+	}, nil
+}
+
+// Validate redeems a ticket. It fails if the ticket was already used, has
+// expired, or if its session or user has been revoked or suspended since.
+func (s *WSTicketService) Validate(ctx context.Context, ticket string) (*dto.WSTicketValidation, error) {
+	data, err := s.redis.GetDel(ctx, wsTicketKey(ticket)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrInvalidWSTicket
+		}
+		return nil, err
+	}
+
+	var validation dto.WSTicketValidation
+	if err := json.Unmarshal(data, &validation); err != nil {
+		return nil, err
+	}
+
+	status, err := s.revocations.Check(ctx, validation.UserID, validation.SessionID, validation.IssuedAt)
+	if err != nil {
+		log.Printf("[WARN] Revocation check failed for websocket ticket of userID=%d: %v", validation.UserID, err)
+	} else if !status.Valid() {
+		return nil, ErrWSTicketRevoked
+	}
+
+	return &validation, nil
+}
@@ -0,0 +1,190 @@
+// Package tlsconfig provides server TLS configuration whose certificate and
+// client CA bundle are reloaded from disk when the files change, so that
+// short-lived certificates can be renewed without a restart.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ParseClientAuth maps the TLS_CLIENT_AUTH setting to a tls.ClientAuthType:
+// none ignores client certificates, request verifies one if the client sends
+// it, and require rejects clients without a valid certificate.
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q", mode)
+	}
+}
+
+// Reloader holds the server certificate and client CAs loaded from files. A
+// Reloader without a certificate file is disabled and listeners serve
+// plaintext.
+type Reloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	clientAuth   tls.ClientAuthType
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  [3]time.Time
+}
+
+func NewReloader(certFile, keyFile, clientCAFile string, clientAuth tls.ClientAuthType) (*Reloader, error) {
+	r := &Reloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		clientAuth:   clientAuth,
+	}
+	if certFile == "" {
+		if keyFile != "" || clientCAFile != "" || clientAuth != tls.NoClientCert {
+			return nil, errors.New("TLS settings require a certificate file")
+		}
+		return r, nil
+	}
+	if keyFile == "" {
+		return nil, errors.New("TLS certificate requires a key file")
+	}
+	if clientAuth != tls.NoClientCert && clientCAFile == "" {
+		return nil, errors.New("client certificate verification requires a client CA file")
+	}
+	return r, r.Reload()
+}
+
+// Enabled reports whether listeners should serve TLS.
+func (r *Reloader) Enabled() bool {
+	return r != nil && r.certFile != ""
+}
+
+// Reload reads the files again if any of them changed since they were last
+// loaded. On error the previous certificate stays in use.
+func (r *Reloader) Reload() error {
+	if !r.Enabled() {
+		return nil
+	}
+
+	var modTimes [3]time.Time
+	for i, path := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTimes == r.modTimes
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		pemBytes, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pemBytes) {
+			return fmt.Errorf("no certificates found in %s", r.clientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.mu.Unlock()
+
+	if cert.Leaf != nil {
+		log.Printf("[INFO] TLS certificate loaded: %s (expires %s)",
+			r.certFile, cert.Leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// RunReloader checks the files for changes every interval until ctx is
+// cancelled.
+func (r *Reloader) RunReloader(ctx context.Context, interval time.Duration) {
+	if !r.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				log.Printf("[ERROR] Failed to reload TLS certificate: %v", err)
+			}
+		}
+	}
+}
+
+// ServerConfig returns a configuration for a listener. Every handshake uses
+// the certificate and client CAs loaded last, so connections opened after a
+// reload see the new files.
+func (r *Reloader) ServerConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	cfg := base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		c := base.Clone()
+		c.Certificates = []tls.Certificate{*r.cert}
+		c.ClientAuth = r.clientAuth
+		c.ClientCAs = r.clientCAs
+		return c, nil
+	}
+	return cfg
+}
+
+// ClientIdentity returns the identity of a verified client certificate: its
+// first URI SAN, such as a SPIFFE ID, or else its common name. It returns ""
+// when the client sent no verified certificate.
+func ClientIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	leaf := state.VerifiedChains[0][0]
+	if len(leaf.URIs) > 0 {
+		return leaf.URIs[0].String()
+	}
+	return leaf.Subject.CommonName
+}
@@ -1,5 +1,5 @@
-DROP TABLE users;
-DROP INDEX idx_users_email;
-DROP INDEX idx_users_username;
-DROP INDEX idx_users_status;
-DROP INDEX idx-users_created_at;
\ No newline at end of file
+DROP INDEX IF EXISTS idx_users_created_at;
+DROP INDEX IF EXISTS idx_users_status;
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
+DROP TABLE IF EXISTS users;
@@ -1,3 +1,3 @@
-DELETE INDEX idx_email_verifications_user_id;
-DELETE INDEX idx_email_verifications_token;
-DELETE TABLE email_verifications;
\ No newline at end of file
+DROP INDEX IF EXISTS idx_email_verifications_token;
+DROP INDEX IF EXISTS idx_email_verifications_user_id;
+DROP TABLE IF EXISTS email_verifications;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_audit_logs_created_at;
+DROP INDEX IF EXISTS idx_audit_logs_target_user_id;
+DROP INDEX IF EXISTS idx_audit_logs_actor_id;
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_suspensions_active;
+DROP INDEX IF EXISTS idx_user_suspensions_user_id;
+DROP TABLE IF EXISTS user_suspensions;
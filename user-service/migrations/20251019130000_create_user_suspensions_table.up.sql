@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_suspensions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    moderator_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    lifted_at TIMESTAMP WITH TIME ZONE,
+    lifted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT user_suspensions_period CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX idx_user_suspensions_user_id ON user_suspensions(user_id);
+CREATE INDEX idx_user_suspensions_active ON user_suspensions(user_id, ends_at) WHERE lifted_at IS NULL;
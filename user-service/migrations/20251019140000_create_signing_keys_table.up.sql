@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id BIGSERIAL PRIMARY KEY,
+    kid VARCHAR(64) UNIQUE NOT NULL,
+    algorithm VARCHAR(16) NOT NULL DEFAULT 'EdDSA',
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_signing_keys_retired_at ON signing_keys(retired_at);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_sessions_user_last_active;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS last_active_at,
+    DROP COLUMN IF EXISTS last_ip_address,
+    DROP COLUMN IF EXISTS device_name,
+    DROP COLUMN IF EXISTS device_type,
+    DROP COLUMN IF EXISTS os,
+    DROP COLUMN IF EXISTS browser;
@@ -0,0 +1,11 @@
+ALTER TABLE sessions
+    ADD COLUMN browser VARCHAR(100),
+    ADD COLUMN os VARCHAR(100),
+    ADD COLUMN device_type VARCHAR(20),
+    ADD COLUMN device_name VARCHAR(100),
+    ADD COLUMN last_ip_address INET,
+    ADD COLUMN last_active_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE sessions SET last_ip_address = ip_address, last_active_at = created_at;
+
+CREATE INDEX idx_sessions_user_last_active ON sessions(user_id, last_active_at DESC) WHERE revoked_at IS NULL;
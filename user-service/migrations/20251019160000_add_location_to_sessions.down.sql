@@ -0,0 +1,3 @@
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS country_code,
+    DROP COLUMN IF EXISTS city;
@@ -0,0 +1,3 @@
+ALTER TABLE sessions
+    ADD COLUMN city VARCHAR(100),
+    ADD COLUMN country_code CHAR(2);
@@ -0,0 +1,5 @@
+UPDATE sessions SET expires_at = absolute_expires_at;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS idle_timeout_seconds,
+    DROP COLUMN IF EXISTS absolute_expires_at;
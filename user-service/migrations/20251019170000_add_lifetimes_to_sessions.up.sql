@@ -0,0 +1,8 @@
+-- expires_at is now the sliding idle deadline, capped at absolute_expires_at.
+ALTER TABLE sessions
+    ADD COLUMN absolute_expires_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN idle_timeout_seconds INTEGER;
+
+UPDATE sessions SET absolute_expires_at = expires_at;
+
+ALTER TABLE sessions ALTER COLUMN absolute_expires_at SET NOT NULL;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS device_authorizations (
+    id BIGSERIAL PRIMARY KEY,
+    device_code_hash CHAR(64) UNIQUE NOT NULL,
+    user_code VARCHAR(16) UNIQUE NOT NULL,
+    client_id VARCHAR(100),
+    user_agent TEXT,
+    ip_address INET,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    interval_seconds INTEGER NOT NULL,
+    last_polled_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT device_authorizations_status CHECK (status IN ('pending', 'approved', 'denied', 'consumed'))
+);
+
+CREATE INDEX idx_device_authorizations_expires_at ON device_authorizations(expires_at);
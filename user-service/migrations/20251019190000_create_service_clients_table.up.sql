@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS service_clients (
+    id BIGSERIAL PRIMARY KEY,
+    client_id VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    secret_hash CHAR(64),
+    tls_identity VARCHAR(255) UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    disabled_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT service_clients_credentials CHECK (secret_hash IS NOT NULL OR tls_identity IS NOT NULL)
+);
@@ -0,0 +1,10 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_presence_override_check;
+ALTER TABLE users RENAME COLUMN presence_override TO status;
+
+UPDATE users SET status = 'offline' WHERE status IS DISTINCT FROM 'busy';
+
+ALTER TABLE users ALTER COLUMN status SET DEFAULT 'offline';
+ALTER TABLE users
+    ADD CONSTRAINT users_status_check CHECK (status IN ('online', 'offline', 'away', 'busy'));
+
+CREATE INDEX idx_users_status ON users(status) WHERE deleted_at IS NULL;
@@ -0,0 +1,12 @@
+-- Presence is derived from heartbeats kept in Redis. Only a manual override
+-- that outlives connections is stored; NULL means automatic.
+DROP INDEX IF EXISTS idx_users_status;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE users RENAME COLUMN status TO presence_override;
+
+UPDATE users SET presence_override = NULL WHERE presence_override IS DISTINCT FROM 'busy';
+
+ALTER TABLE users
+    ADD CONSTRAINT users_presence_override_check CHECK (presence_override IN ('busy', 'invisible'));
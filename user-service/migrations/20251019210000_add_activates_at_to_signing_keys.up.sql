@@ -0,0 +1,3 @@
+ALTER TABLE signing_keys ADD COLUMN activates_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE signing_keys SET activates_at = created_at;
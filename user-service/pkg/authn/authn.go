@@ -0,0 +1,321 @@
+// Package authn lets other services authenticate requests carrying
+// user-service access tokens the way user-service itself does, without
+// calling it on every request.
+//
+// Tokens are verified locally against the Ed25519 keys published at
+// /.well-known/jwks.json, which are cached and refetched when a token names an
+// unknown key. Whether a valid token has since been revoked is answered by a
+// RevocationChecker: RedisRevocation reads the shared revocation schema,
+// Introspection asks user-service. Middleware, Gin and the gRPC interceptors
+// put the resulting Principal in the request context.
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zhanserikAmangeldi/user-service/pkg/dpop"
+	userjwt "github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const dpopScheme = "DPoP"
+
+var (
+	ErrMissingToken       = errors.New("authorization header required")
+	ErrInvalidHeader      = errors.New("invalid authorization header format")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrServiceToken       = errors.New("service tokens are not accepted here")
+	ErrNotDPoPBound       = errors.New("token is not DPoP-bound")
+	ErrDPoPSchemeRequired = errors.New("DPoP-bound token requires the DPoP scheme")
+	ErrDPoPUnsupported    = errors.New("DPoP-bound tokens are not accepted here")
+	ErrInvalidDPoPProof   = errors.New("invalid DPoP proof")
+	ErrRevoked            = errors.New("token revoked")
+	ErrSuspended          = errors.New("account suspended")
+	// ErrUnavailable is returned when revocation could not be checked and
+	// the Verifier was created WithStrictRevocation.
+	ErrUnavailable = errors.New("revocation status unavailable")
+)
+
+// SuspendedError is returned for the token of a suspended user. It matches
+// ErrSuspended with errors.Is.
+type SuspendedError struct {
+	// Until is nil for an indefinite suspension.
+	Until *time.Time
+}
+
+func (e *SuspendedError) Error() string {
+	return ErrSuspended.Error()
+}
+
+func (e *SuspendedError) Is(target error) bool {
+	return target == ErrSuspended
+}
+
+// Principal is the authenticated caller. Service tokens, when accepted, have
+// a ClientID and Scopes and no user fields.
+type Principal struct {
+	UserID    int64
+	Username  string
+	Email     string
+	SessionID int64
+	// ImpersonatorID is the admin acting as the user, or 0.
+	ImpersonatorID int64
+	ClientID       string
+	Scopes         []string
+	// KeyThumbprint is the DPoP key the token is bound to, or "".
+	KeyThumbprint string
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+}
+
+func (p *Principal) IsService() bool {
+	return p.ClientID != ""
+}
+
+func (p *Principal) IsImpersonated() bool {
+	return p.ImpersonatorID != 0
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier authenticates access tokens. It is safe for concurrent use.
+type Verifier struct {
+	keys          *keySet
+	hmacSecret    []byte
+	revocations   RevocationChecker
+	strict        bool
+	serviceTokens bool
+	proofs        *dpop.Verifier
+}
+
+type Option func(*Verifier)
+
+// WithHTTPClient sets the client the key set is fetched with.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(v *Verifier) {
+		v.keys.httpClient = httpClient
+	}
+}
+
+// WithKeyRefresh sets how long fetched keys are used before being refetched,
+// and how often at most an unknown key may trigger a refetch.
+func WithKeyRefresh(maxAge, minInterval time.Duration) Option {
+	return func(v *Verifier) {
+		v.keys.maxAge = maxAge
+		v.keys.minInterval = minInterval
+	}
+}
+
+// WithHMACSecret also accepts tokens signed with the shared secret, which
+// user-service issues until Ed25519 signing keys have been generated. Only
+// services trusted to hold the secret, and so to mint tokens, may use it.
+func WithHMACSecret(secret string) Option {
+	return func(v *Verifier) {
+		v.hmacSecret = []byte(secret)
+	}
+}
+
+// WithRevocation checks every verified user token with checker. Without it
+// revoked tokens stay valid until they expire.
+func WithRevocation(checker RevocationChecker) Option {
+	return func(v *Verifier) {
+		v.revocations = checker
+	}
+}
+
+// WithStrictRevocation rejects tokens with ErrUnavailable when revocation
+// cannot be checked, instead of accepting them like user-service does.
+func WithStrictRevocation() Option {
+	return func(v *Verifier) {
+		v.strict = true
+	}
+}
+
+// WithServiceTokens accepts service tokens issued with client credentials.
+// Handlers should check the scope of such a Principal.
+func WithServiceTokens() Option {
+	return func(v *Verifier) {
+		v.serviceTokens = true
+	}
+}
+
+// WithDPoP accepts DPoP-bound tokens on HTTP requests that carry a proof by
+// the bound key. Without it such tokens are rejected.
+func WithDPoP(proofs *dpop.Verifier) Option {
+	return func(v *Verifier) {
+		v.proofs = proofs
+	}
+}
+
+// NewVerifier returns a Verifier for tokens signed with the keys published at
+// jwksURL, such as https://users.internal/.well-known/jwks.json.
+func NewVerifier(jwksURL string, opts ...Option) *Verifier {
+	v := &Verifier{keys: newKeySet(jwksURL)}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Refresh fetches the key set, for example to fail fast at startup.
+func (v *Verifier) Refresh(ctx context.Context) error {
+	return v.keys.refresh(ctx)
+}
+
+// Verify authenticates a bare bearer token, such as one passed in gRPC
+// metadata. DPoP-bound tokens are rejected, as there is no request for a
+// proof to cover.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	principal, err := v.parse(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if principal.KeyThumbprint != "" {
+		return nil, ErrDPoPUnsupported
+	}
+	if err := v.checkRevocation(ctx, token, principal); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+// Authenticate authenticates the Authorization header of r, and its DPoP
+// proof if the token is bound to a key.
+func (v *Verifier) Authenticate(r *http.Request) (*Principal, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, ErrMissingToken
+	}
+
+	scheme, token, ok := strings.Cut(authHeader, " ")
+	if !ok || (scheme != "Bearer" && scheme != dpopScheme) {
+		return nil, ErrInvalidHeader
+	}
+
+	principal, err := v.parse(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	if principal.KeyThumbprint != "" || scheme == dpopScheme {
+		if err := v.verifyProof(r, scheme, token, principal.KeyThumbprint); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.checkRevocation(r.Context(), token, principal); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+func (v *Verifier) parse(ctx context.Context, tokenString string) (*Principal, error) {
+	methods := []string{jwt.SigningMethodEdDSA.Alg()}
+	if len(v.hmacSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return v.hmacSecret, nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.key(ctx, kid)
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &userjwt.Claims{}, keyFunc, jwt.WithValidMethods(methods))
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	claims := token.Claims.(*userjwt.Claims)
+	if !claims.IsAccessToken() {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.IsService() && !v.serviceTokens {
+		return nil, ErrServiceToken
+	}
+
+	principal := &Principal{
+		UserID:         claims.UserId,
+		Username:       claims.Username,
+		Email:          claims.Email,
+		SessionID:      claims.SessionID,
+		ImpersonatorID: claims.ActorID(),
+		ClientID:       claims.ClientID,
+		Scopes:         strings.Fields(claims.Scope),
+		KeyThumbprint:  claims.KeyThumbprint(),
+	}
+	if claims.IssuedAt != nil {
+		principal.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		principal.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return principal, nil
+}
+
+func (v *Verifier) verifyProof(r *http.Request, scheme, token, jkt string) error {
+	if jkt == "" {
+		return ErrNotDPoPBound
+	}
+	if scheme != dpopScheme {
+		return ErrDPoPSchemeRequired
+	}
+	if v.proofs == nil {
+		return ErrDPoPUnsupported
+	}
+
+	proof, err := v.proofs.Verify(r.Context(), r.Header.Get(dpop.HeaderName), r.Method, RequestURL(r), token)
+	if err != nil {
+		if !errors.Is(err, dpop.ErrUnavailable) {
+			return fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+		}
+		log.Printf("[WARN] Accepting DPoP proof without replay check: %v", err)
+	}
+	if proof.Thumbprint != jkt {
+		return fmt.Errorf("%w: proof key does not match the token", ErrInvalidDPoPProof)
+	}
+	return nil
+}
+
+func (v *Verifier) checkRevocation(ctx context.Context, token string, principal *Principal) error {
+	if v.revocations == nil || principal.IsService() {
+		return nil
+	}
+
+	err := v.revocations.Check(ctx, token, principal)
+	if err == nil || errors.Is(err, ErrRevoked) || errors.Is(err, ErrSuspended) {
+		return err
+	}
+	if v.strict {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	log.Printf("[WARN] Accepting token of userID=%d without revocation check: %v", principal.UserID, err)
+	return nil
+}
+
+// RequestURL reconstructs the URL the client addressed, as a DPoP proof's htu
+// names it, honouring X-Forwarded-Proto set by a TLS-terminating proxy.
+func RequestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
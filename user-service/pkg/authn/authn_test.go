@@ -0,0 +1,510 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	userjwt "github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testSecret = "test-secret"
+
+// issuer imitates user-service: it signs tokens with an Ed25519 key and
+// publishes its keys, counting how often they are fetched.
+type issuer struct {
+	tm      *userjwt.TokenManager
+	server  *httptest.Server
+	fetches atomic.Int32
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	iss := &issuer{tm: userjwt.NewTokenManager(testSecret)}
+	iss.rotate(t)
+	iss.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		json.NewEncoder(w).Encode(userjwt.NewJWKS(iss.tm.VerificationKeys()))
+	}))
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *issuer) rotate(t *testing.T) {
+	t.Helper()
+	key, err := userjwt.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	iss.tm.SetSigningKeys(key, iss.tm.VerificationKeys())
+}
+
+func (iss *issuer) userToken(t *testing.T, opts ...userjwt.TokenOption) string {
+	t.Helper()
+	token, _, err := iss.tm.GenerateAccessToken(7, "alice", "alice@example.com", append([]userjwt.TokenOption{userjwt.WithSessionID(42)}, opts...)...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return token
+}
+
+func (iss *issuer) verifier(opts ...Option) *Verifier {
+	return NewVerifier(iss.server.URL, opts...)
+}
+
+func TestVerify_ValidToken(t *testing.T) {
+	iss := newIssuer(t)
+	v := iss.verifier()
+
+	principal, err := v.Verify(context.Background(), iss.userToken(t, userjwt.WithActor(3)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if principal.UserID != 7 || principal.Username != "alice" || principal.Email != "alice@example.com" ||
+		principal.SessionID != 42 || principal.ImpersonatorID != 3 || principal.IsService() {
+		t.Errorf("unexpected principal: %+v", principal)
+	}
+	if principal.ExpiresAt.IsZero() || principal.IssuedAt.IsZero() {
+		t.Errorf("expected token times to be set: %+v", principal)
+	}
+}
+
+func TestVerify_RejectsBadTokens(t *testing.T) {
+	iss := newIssuer(t)
+	other := newIssuer(t)
+	v := iss.verifier()
+
+	refreshToken, _, err := iss.tm.GenerateRefreshToken(7, "alice", "alice@example.com", userjwt.WithSessionID(42))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, token := range map[string]string{
+		"garbage":       "not.a.token",
+		"foreign key":   other.userToken(t),
+		"expired":       iss.userToken(t, userjwt.WithTTL(-time.Minute)),
+		"tampered sig":  iss.userToken(t) + "x",
+		"refresh token": refreshToken,
+	} {
+		if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestVerify_HMACOnlyWithSecret(t *testing.T) {
+	iss := newIssuer(t)
+	hmacIssuer := userjwt.NewTokenManager(testSecret)
+	token, _, err := hmacIssuer.GenerateAccessToken(7, "alice", "alice@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := iss.verifier().Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected an HMAC token to be rejected without the secret, got %v", err)
+	}
+	if _, err := iss.verifier(WithHMACSecret(testSecret)).Verify(context.Background(), token); err != nil {
+		t.Errorf("expected an HMAC token to be accepted with the secret, got %v", err)
+	}
+	if _, err := iss.verifier(WithHMACSecret("other")).Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected an HMAC token to be rejected with the wrong secret, got %v", err)
+	}
+}
+
+func TestVerify_RefetchesKeysOnRotation(t *testing.T) {
+	iss := newIssuer(t)
+	v := iss.verifier(WithKeyRefresh(time.Hour, 0))
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, iss.userToken(t)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := v.Verify(ctx, iss.userToken(t)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := iss.fetches.Load(); got != 1 {
+		t.Fatalf("expected the keys to be fetched once, got %d", got)
+	}
+
+	iss.rotate(t)
+	if _, err := v.Verify(ctx, iss.userToken(t)); err != nil {
+		t.Fatalf("expected a token signed with a rotated key to verify, got %v", err)
+	}
+	if got := iss.fetches.Load(); got != 2 {
+		t.Errorf("expected the keys to be refetched once, got %d", got)
+	}
+}
+
+func TestVerify_RateLimitsUnknownKeys(t *testing.T) {
+	iss := newIssuer(t)
+	v := iss.verifier(WithKeyRefresh(time.Hour, time.Hour))
+	ctx := context.Background()
+
+	if err := v.Refresh(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other := newIssuer(t)
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(ctx, other.userToken(t)); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	}
+	if got := iss.fetches.Load(); got != 1 {
+		t.Errorf("expected unknown keys not to trigger refetches, got %d fetches", got)
+	}
+}
+
+func TestVerify_SlowFetchDoesNotBlockCachedKeys(t *testing.T) {
+	iss := newIssuer(t)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(userjwt.NewJWKS(iss.tm.VerificationKeys()))
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	v := NewVerifier(server.URL, WithKeyRefresh(time.Hour, 0))
+	ctx := context.Background()
+	if err := v.Refresh(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	other := newIssuer(t)
+	unknownCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	go v.Verify(unknownCtx, other.userToken(t))
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	verified := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(ctx, iss.userToken(t))
+		verified <- err
+	}()
+	select {
+	case err := <-verified:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Error("expected a token with a cached key to verify while a fetch is in flight")
+	}
+}
+
+func TestVerify_KeepsKeysWhenFetchFails(t *testing.T) {
+	iss := newIssuer(t)
+	v := iss.verifier(WithKeyRefresh(0, 0))
+	ctx := context.Background()
+
+	token := iss.userToken(t)
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	iss.server.Close()
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Errorf("expected stale keys to be used when the key set is unreachable, got %v", err)
+	}
+}
+
+func TestVerify_ServiceTokens(t *testing.T) {
+	iss := newIssuer(t)
+	token, _, err := iss.tm.GenerateServiceToken("chat-service", []string{"users:read"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := iss.verifier().Verify(context.Background(), token); !errors.Is(err, ErrServiceToken) {
+		t.Errorf("expected ErrServiceToken, got %v", err)
+	}
+
+	principal, err := iss.verifier(WithServiceTokens()).Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !principal.IsService() || principal.ClientID != "chat-service" || !principal.HasScope("users:read") || principal.HasScope("sessions:revoke") {
+		t.Errorf("unexpected principal: %+v", principal)
+	}
+}
+
+func TestVerify_RejectsDPoPBoundTokens(t *testing.T) {
+	iss := newIssuer(t)
+
+	_, err := iss.verifier().Verify(context.Background(), iss.userToken(t, userjwt.WithKeyThumbprint("thumbprint")))
+	if !errors.Is(err, ErrDPoPUnsupported) {
+		t.Errorf("expected ErrDPoPUnsupported, got %v", err)
+	}
+}
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestRedisRevocation(t *testing.T) {
+	iss := newIssuer(t)
+	_, client := newRedis(t)
+	store := revocation.NewStore(client, userjwt.AccessTokenTTL, nil)
+	v := iss.verifier(WithRevocation(NewRedisRevocation(client, 0)))
+	ctx := context.Background()
+	token := iss.userToken(t)
+
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	endsAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := store.Suspend(ctx, 7, &endsAt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := v.Verify(ctx, token)
+	var suspended *SuspendedError
+	if !errors.As(err, &suspended) || suspended.Until == nil || !suspended.Until.Equal(endsAt) {
+		t.Fatalf("expected a SuspendedError until %v, got %v", endsAt, err)
+	}
+	if !errors.Is(err, ErrSuspended) {
+		t.Errorf("expected the error to match ErrSuspended")
+	}
+
+	if err := store.Unsuspend(ctx, 7); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.RevokeSessions(ctx, 7, 42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := v.Verify(ctx, token); !errors.Is(err, ErrRevoked) {
+		t.Errorf("expected ErrRevoked, got %v", err)
+	}
+}
+
+func TestRedisRevocation_Unavailable(t *testing.T) {
+	iss := newIssuer(t)
+	mr, client := newRedis(t)
+	checker := NewRedisRevocation(client, 0)
+	token := iss.userToken(t)
+	mr.Close()
+
+	if _, err := iss.verifier(WithRevocation(checker)).Verify(context.Background(), token); err != nil {
+		t.Errorf("expected the token to be accepted when Redis is down, got %v", err)
+	}
+	_, err := iss.verifier(WithRevocation(checker), WithStrictRevocation()).Verify(context.Background(), token)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable in strict mode, got %v", err)
+	}
+}
+
+// introspectionServer imitates the token and introspection endpoints of
+// user-service. Tokens in inactive are reported inactive.
+type introspectionServer struct {
+	server         *httptest.Server
+	inactive       map[string]bool
+	tokens         atomic.Int32
+	introspections atomic.Int32
+}
+
+func newIntrospectionServer(t *testing.T) *introspectionServer {
+	t.Helper()
+	s := &introspectionServer{inactive: make(map[string]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != "chat-service" || secret != "s3cret" || r.FormValue("scope") != "tokens:validate" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		s.tokens.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "service-token", "token_type": "Bearer", "expires_in": 900})
+	})
+	mux.HandleFunc("POST /api/v1/auth/introspect", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer service-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.introspections.Add(1)
+		json.NewEncoder(w).Encode(map[string]bool{"active": !s.inactive[r.FormValue("token")]})
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func TestIntrospection(t *testing.T) {
+	iss := newIssuer(t)
+	srv := newIntrospectionServer(t)
+	v := iss.verifier(WithRevocation(NewIntrospection(srv.server.URL, "chat-service", "s3cret", time.Minute, nil)))
+	ctx := context.Background()
+
+	active, revoked := iss.userToken(t), iss.userToken(t, userjwt.WithSessionID(43))
+	srv.inactive[revoked] = true
+
+	for i := 0; i < 2; i++ {
+		if _, err := v.Verify(ctx, active); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := v.Verify(ctx, revoked); !errors.Is(err, ErrRevoked) {
+			t.Fatalf("expected ErrRevoked, got %v", err)
+		}
+	}
+
+	if got := srv.introspections.Load(); got != 2 {
+		t.Errorf("expected answers to be cached, got %d introspections", got)
+	}
+	if got := srv.tokens.Load(); got != 1 {
+		t.Errorf("expected the service token to be reused, got %d token requests", got)
+	}
+}
+
+func TestIntrospection_BadCredentials(t *testing.T) {
+	iss := newIssuer(t)
+	srv := newIntrospectionServer(t)
+	checker := NewIntrospection(srv.server.URL, "chat-service", "wrong", time.Minute, nil)
+
+	_, err := iss.verifier(WithRevocation(checker), WithStrictRevocation()).Verify(context.Background(), iss.userToken(t))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	iss := newIssuer(t)
+	_, client := newRedis(t)
+	store := revocation.NewStore(client, userjwt.AccessTokenTTL, nil)
+	v := iss.verifier(WithRevocation(NewRedisRevocation(client, 0)))
+
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("expected a principal in the context")
+			return
+		}
+		w.Write([]byte(principal.Username))
+	}))
+
+	serve := func(authHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/messages", nil)
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	token := iss.userToken(t)
+	if rec := serve("Bearer " + token); rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+		t.Errorf("expected 200 alice, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+	if rec := serve("Basic " + token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for another scheme, got %d", rec.Code)
+	}
+	if rec := serve("DPoP " + token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a bearer token sent with the DPoP scheme, got %d", rec.Code)
+	}
+
+	if err := store.Suspend(context.Background(), 7, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rec := serve("Bearer " + token)
+	var body errorBody
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusForbidden || body.Error != "account_suspended" {
+		t.Errorf("expected 403 account_suspended, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	iss := newIssuer(t)
+	v := iss.verifier()
+
+	router := gin.New()
+	router.GET("/me", v.Gin(), func(c *gin.Context) {
+		principal := GinPrincipal(c)
+		fromContext, _ := FromContext(c.Request.Context())
+		if principal == nil || principal != fromContext {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, principal.Username)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+iss.userToken(t))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+		t.Errorf("expected 200 alice, got %d %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var body errorBody
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusUnauthorized || body.Error != ErrInvalidToken.Error() {
+		t.Errorf("expected 401 %q, got %d %s", ErrInvalidToken, rec.Code, rec.Body)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	iss := newIssuer(t)
+	interceptor := iss.verifier().UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/chat.v1.ChatService/Send"}
+
+	call := func(md metadata.MD) (*Principal, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		resp, err := interceptor(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+			principal, _ := FromContext(ctx)
+			return principal, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*Principal), nil
+	}
+
+	principal, err := call(metadata.Pairs("authorization", "Bearer "+iss.userToken(t)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if principal == nil || principal.UserID != 7 {
+		t.Errorf("unexpected principal: %+v", principal)
+	}
+
+	for name, md := range map[string]metadata.MD{
+		"missing": metadata.MD{},
+		"invalid": metadata.Pairs("authorization", "Bearer invalid"),
+		"bound":   metadata.Pairs("authorization", "Bearer "+iss.userToken(t, userjwt.WithKeyThumbprint("thumbprint"))),
+	} {
+		if _, err := call(md); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: expected Unauthenticated, got %v", name, err)
+		}
+	}
+}
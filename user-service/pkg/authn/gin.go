@@ -0,0 +1,37 @@
+package authn
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+)
+
+const ginPrincipalKey = "authn.principal"
+
+// Gin is Middleware for gin. The Principal is available from GinPrincipal
+// and from the request context.
+func (v *Verifier) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := v.Authenticate(c.Request)
+		if err != nil {
+			status, body := errorResponse(err)
+			if errors.Is(err, ErrInvalidDPoPProof) {
+				c.Header("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+			}
+			c.AbortWithStatusJSON(status, body)
+			return
+		}
+
+		c.Set(ginPrincipalKey, principal)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// GinPrincipal returns the Principal authenticated by Gin, or nil.
+func GinPrincipal(c *gin.Context) *Principal {
+	principal, exists := c.Get(ginPrincipalKey)
+	if !exists {
+		return nil
+	}
+	return principal.(*Principal)
+}
@@ -0,0 +1,67 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying principal.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the Principal put in ctx by one of the adapters.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// Middleware rejects requests that Authenticate fails with the same
+// responses as user-service, and serves the others with the Principal in
+// the request context.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := v.Authenticate(r)
+		if err != nil {
+			status, body := errorResponse(err)
+			if errors.Is(err, ErrInvalidDPoPProof) {
+				w.Header().Set("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(body)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+	})
+}
+
+type errorBody struct {
+	Error   string     `json:"error"`
+	Message string     `json:"message,omitempty"`
+	EndsAt  *time.Time `json:"ends_at,omitempty"`
+}
+
+func errorResponse(err error) (int, errorBody) {
+	var suspended *SuspendedError
+	switch {
+	case errors.As(err, &suspended):
+		return http.StatusForbidden, errorBody{
+			Error:   "account_suspended",
+			Message: "Your account has been suspended",
+			EndsAt:  suspended.Until,
+		}
+	case errors.Is(err, ErrInvalidDPoPProof):
+		return http.StatusUnauthorized, errorBody{Error: "invalid_dpop_proof", Message: err.Error()}
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable, errorBody{Error: "service_unavailable", Message: err.Error()}
+	default:
+		return http.StatusUnauthorized, errorBody{Error: err.Error()}
+	}
+}
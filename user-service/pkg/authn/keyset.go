@@ -0,0 +1,150 @@
+package authn
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	userjwt "github.com/zhanserikAmangeldi/user-service/pkg/jwt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultKeyMaxAge      = time.Hour
+	defaultKeyMinInterval = 30 * time.Second
+	maxJWKSSize           = 1 << 20
+)
+
+// keySet caches the published verification keys. A token naming a key that
+// is not cached triggers a refetch, at most once per minInterval, so that
+// keys rotated in by user-service are picked up and garbage kids cannot be
+// used to flood it. If a refetch fails the keys already fetched stay in use.
+//
+// Fetches run without holding mu, so tokens whose key is cached are never
+// held up by one; only tokens waiting for an unknown kid wait for it.
+type keySet struct {
+	url         string
+	httpClient  *http.Client
+	maxAge      time.Duration
+	minInterval time.Duration
+
+	mu          sync.Mutex
+	keys        map[string]ed25519.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// fetching is closed when the fetch in flight, if any, has finished.
+	fetching chan struct{}
+}
+
+func newKeySet(url string) *keySet {
+	return &keySet{
+		url:         url,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		maxAge:      defaultKeyMaxAge,
+		minInterval: defaultKeyMinInterval,
+	}
+}
+
+func (s *keySet) key(ctx context.Context, kid string) (ed25519.PublicKey, error) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	stale := time.Since(s.fetchedAt) > s.maxAge
+	done := s.fetching
+	if done == nil && (!ok || stale) && time.Since(s.lastAttempt) >= s.minInterval {
+		done = s.startFetchLocked(ctx)
+	}
+	s.mu.Unlock()
+
+	// A stale key is still used while the refetch runs in the background.
+	if ok {
+		return key, nil
+	}
+	if done == nil {
+		return nil, ErrInvalidToken
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	key, ok = s.keys[kid]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+// startFetchLocked refetches the keys in the background. The fetch outlives
+// the request that triggered it, as other requests may be waiting for it.
+func (s *keySet) startFetchLocked(ctx context.Context) chan struct{} {
+	done := make(chan struct{})
+	s.fetching = done
+	s.lastAttempt = time.Now()
+
+	go func() {
+		defer close(done)
+
+		keys, err := s.fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			log.Printf("[WARN] Failed to fetch JWKS from %s: %v", s.url, err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetching = nil
+		if err == nil {
+			s.keys = keys
+			s.fetchedAt = time.Now()
+		}
+	}()
+
+	return done
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+func (s *keySet) fetch(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var set userjwt.JWKS
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return nil, err
+	}
+	return set.PublicKeys()
+}
@@ -0,0 +1,256 @@
+package authn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/zhanserikAmangeldi/user-service/pkg/revocation"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RevocationChecker reports whether a verified user token has been revoked.
+// Check returns nil, ErrRevoked, a *SuspendedError, or any other error when
+// it could not find out.
+type RevocationChecker interface {
+	Check(ctx context.Context, token string, principal *Principal) error
+}
+
+// RedisRevocation reads the revocation schema user-service writes, so it
+// needs a connection to the same Redis.
+type RedisRevocation struct {
+	checker *revocation.Checker
+}
+
+// NewRedisRevocation returns a RedisRevocation that caches answers for
+// cacheTTL, which delays revocations by as much. A zero cacheTTL disables
+// caching.
+func NewRedisRevocation(client redis.UniversalClient, cacheTTL time.Duration) *RedisRevocation {
+	return &RedisRevocation{checker: revocation.NewChecker(client, cacheTTL)}
+}
+
+func (r *RedisRevocation) Check(ctx context.Context, _ string, principal *Principal) error {
+	status, err := r.checker.Check(ctx, principal.UserID, principal.SessionID, principal.IssuedAt)
+	if err != nil {
+		return err
+	}
+	if status.Suspended {
+		return &SuspendedError{Until: status.SuspendedUntil}
+	}
+	if status.Revoked {
+		return ErrRevoked
+	}
+	return nil
+}
+
+const (
+	introspectionScope     = "tokens:validate"
+	maxIntrospectionCached = 10_000
+)
+
+// Introspection asks the introspection endpoint of user-service whether a
+// token is still active, authenticating as a service client with the
+// tokens:validate scope. The endpoint does not say why a token is inactive,
+// so tokens of suspended users fail with ErrRevoked.
+type Introspection struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	cacheTTL     time.Duration
+
+	tokenMu        sync.Mutex
+	serviceToken   string
+	tokenExpiresAt time.Time
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]introspectionEntry
+}
+
+type introspectionEntry struct {
+	active    bool
+	expiresAt time.Time
+}
+
+// NewIntrospection returns an Introspection against the user-service at
+// baseURL. Answers are cached for cacheTTL. clientSecret may be empty when
+// httpClient presents a client certificate registered for clientID; a nil
+// httpClient uses a default one.
+func NewIntrospection(baseURL, clientID, clientSecret string, cacheTTL time.Duration, httpClient *http.Client) *Introspection {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Introspection{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   httpClient,
+		cacheTTL:     cacheTTL,
+		cache:        make(map[[sha256.Size]byte]introspectionEntry),
+	}
+}
+
+func (i *Introspection) Check(ctx context.Context, token string, _ *Principal) error {
+	key := sha256.Sum256([]byte(token))
+
+	active, ok := i.cached(key)
+	if !ok {
+		var err error
+		active, err = i.introspect(ctx, token)
+		if err != nil {
+			return err
+		}
+		i.store(key, active)
+	}
+
+	if !active {
+		return ErrRevoked
+	}
+	return nil
+}
+
+func (i *Introspection) introspect(ctx context.Context, token string) (bool, error) {
+	serviceToken, err := i.token(ctx, false)
+	if err != nil {
+		return false, err
+	}
+
+	active, status, err := i.post(ctx, token, serviceToken)
+	if err == nil && status == http.StatusUnauthorized {
+		// The service token expired early or its client was rotated.
+		if serviceToken, err = i.token(ctx, true); err != nil {
+			return false, err
+		}
+		active, status, err = i.post(ctx, token, serviceToken)
+	}
+	if err != nil {
+		return false, err
+	}
+	if status != http.StatusOK {
+		return false, fmt.Errorf("introspection failed with status %d", status)
+	}
+	return active, nil
+}
+
+func (i *Introspection) post(ctx context.Context, token, serviceToken string) (bool, int, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.baseURL+"/api/v1/auth/introspect", strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+serviceToken)
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return false, resp.StatusCode, nil
+	}
+
+	var body struct {
+		Active bool `json:"active"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, 0, err
+	}
+	return body.Active, resp.StatusCode, nil
+}
+
+// token returns a service token, requesting a new one when the current one
+// is about to expire or force is set.
+func (i *Introspection) token(ctx context.Context, force bool) (string, error) {
+	i.tokenMu.Lock()
+	defer i.tokenMu.Unlock()
+
+	if !force && i.serviceToken != "" && time.Until(i.tokenExpiresAt) > 30*time.Second {
+		return i.serviceToken, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {introspectionScope}}
+	if i.clientSecret == "" {
+		form.Set("client_id", i.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.baseURL+"/api/v1/auth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if i.clientSecret != "" {
+		req.SetBasicAuth(i.clientID, i.clientSecret)
+	}
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", fmt.Errorf("client credentials grant failed with status %d: %s", resp.StatusCode, body.Error)
+	}
+
+	i.serviceToken = body.AccessToken
+	i.tokenExpiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	return i.serviceToken, nil
+}
+
+func (i *Introspection) cached(key [sha256.Size]byte) (bool, bool) {
+	if i.cacheTTL <= 0 {
+		return false, false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entry, ok := i.cache[key]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(i.cache, key)
+		return false, false
+	}
+	return entry.active, true
+}
+
+func (i *Introspection) store(key [sha256.Size]byte, active bool) {
+	if i.cacheTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if len(i.cache) >= maxIntrospectionCached {
+		for k, e := range i.cache {
+			if now.After(e.expiresAt) {
+				delete(i.cache, k)
+			}
+		}
+		if len(i.cache) >= maxIntrospectionCached {
+			i.cache = make(map[[sha256.Size]byte]introspectionEntry)
+		}
+	}
+	i.cache[key] = introspectionEntry{active: active, expiresAt: now.Add(i.cacheTTL)}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Register creates an account and signs the new user in.
+func (c *Client) Register(ctx context.Context, req *RegisterRequest) (*AuthResponse, error) {
+	return c.signIn(ctx, "/api/v1/auth/register", req)
+}
+
+// Login signs the user in and stores the tokens.
+func (c *Client) Login(ctx context.Context, req *LoginRequest) (*AuthResponse, error) {
+	return c.signIn(ctx, "/api/v1/auth/login", req)
+}
+
+// Refresh rotates the stored tokens. Authenticated calls do this by
+// themselves when the access token has expired.
+func (c *Client) Refresh(ctx context.Context) (*AuthResponse, error) {
+	tokens, err := c.tokens.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil || tokens.RefreshToken == "" {
+		return nil, ErrNotAuthenticated
+	}
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	return c.refresh(ctx, tokens.RefreshToken)
+}
+
+// refresh exchanges refreshToken for new tokens. If the server rejects it,
+// the session is over and the stored tokens are cleared.
+func (c *Client) refresh(ctx context.Context, refreshToken string) (*AuthResponse, error) {
+	resp := &AuthResponse{}
+	err := c.do(ctx, http.MethodPost, "/api/v1/auth/refresh", map[string]string{"refresh_token": refreshToken}, resp, false)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			_ = c.tokens.Clear(ctx)
+		}
+		return nil, err
+	}
+
+	if err := c.saveTokens(ctx, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Logout ends the current session and clears the stored tokens.
+func (c *Client) Logout(ctx context.Context) error {
+	tokens, err := c.tokens.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if tokens == nil {
+		return ErrNotAuthenticated
+	}
+
+	req := map[string]string{"access_token": tokens.AccessToken, "refresh_token": tokens.RefreshToken}
+	err = c.do(ctx, http.MethodPost, "/api/v1/auth/logout", req, nil, false)
+	if clearErr := c.tokens.Clear(ctx); err == nil {
+		err = clearErr
+	}
+	return err
+}
+
+// LogoutAll ends every session of the user, including this one.
+func (c *Client) LogoutAll(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, "/api/v1/auth/logout-all", nil, nil, true); err != nil {
+		return err
+	}
+	return c.tokens.Clear(ctx)
+}
+
+// LogoutOthers ends every session of the user except this one and returns
+// how many were ended.
+func (c *Client) LogoutOthers(ctx context.Context) (int, error) {
+	var resp struct {
+		Revoked int `json:"revoked"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/auth/logout-others", nil, &resp, true); err != nil {
+		return 0, err
+	}
+	return resp.Revoked, nil
+}
+
+func (c *Client) Sessions(ctx context.Context) (*SessionList, error) {
+	path := "/api/v1/auth/sessions"
+	if tokens, err := c.tokens.Load(ctx); err == nil && tokens != nil && tokens.RefreshToken != "" {
+		path += "?current_token=" + url.QueryEscape(tokens.RefreshToken)
+	}
+
+	resp := &SessionList{}
+	if err := c.do(ctx, http.MethodGet, path, nil, resp, true); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) RevokeSession(ctx context.Context, sessionID int64) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/auth/sessions/"+strconv.FormatInt(sessionID, 10), nil, nil, true)
+}
+
+// ClientCredentials obtains a service token for a registered service client.
+// With no scopes the token gets every scope of the client. The token is not
+// stored; it is meant for service-to-service calls.
+func (c *Client) ClientCredentials(ctx context.Context, clientID, clientSecret string, scopes ...string) (*ServiceToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/auth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	token := &ServiceToken{}
+	if err := decodeResponse(resp, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (c *Client) signIn(ctx context.Context, path string, req any) (*AuthResponse, error) {
+	resp := &AuthResponse{}
+	if err := c.do(ctx, http.MethodPost, path, req, resp, false); err != nil {
+		return nil, err
+	}
+
+	if err := c.saveTokens(ctx, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) saveTokens(ctx context.Context, resp *AuthResponse) error {
+	return c.tokens.Save(ctx, &Tokens{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+	})
+}
@@ -0,0 +1,274 @@
+// Package client is a Go SDK for the HTTP API of user-service, with an
+// optional gRPC transport for service-to-service lookups.
+//
+// The client signs users in with bearer tokens kept in a TokenStore. When an
+// authenticated call is rejected with 401 it refreshes the tokens once and
+// repeats the call. Idempotent calls are retried with exponential backoff
+// on network errors and on 429, 502, 503 and 504 responses. Cookie mode and
+// DPoP-bound tokens are not supported.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	userv1 "github.com/zhanserikAmangeldi/user-service/pkg/pb/user/v1"
+	"google.golang.org/grpc"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotAuthenticated is returned by calls that need a signed-in user
+	// when the token store is empty.
+	ErrNotAuthenticated = errors.New("user-service: not signed in")
+	// ErrNoGRPC is returned by calls only available over gRPC when the
+	// client has no gRPC connection.
+	ErrNoGRPC = errors.New("user-service: no gRPC connection configured")
+)
+
+// RetryPolicy controls retries of idempotent calls. MaxAttempts counts the
+// first attempt, so 1 disables retries.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	tokens     TokenStore
+	retry      RetryPolicy
+	users      userv1.UserServiceClient
+
+	// refreshMu makes concurrent calls that hit an expired token share one
+	// refresh instead of racing to rotate the refresh token.
+	refreshMu sync.Mutex
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithTokenStore(store TokenStore) Option {
+	return func(c *Client) {
+		c.tokens = store
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithGRPC sends user lookups and token validation over conn instead of
+// HTTP. The connection must carry service credentials, e.g. a client
+// certificate or per-RPC credentials with a service token.
+func WithGRPC(conn grpc.ClientConnInterface) Option {
+	return func(c *Client) {
+		c.users = userv1.NewUserServiceClient(conn)
+	}
+}
+
+// New returns a client for the user-service at baseURL, such as
+// "https://users.example.com".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		tokens:     NewMemoryStore(),
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c
+}
+
+// Tokens returns the tokens of the signed-in user, or nil.
+func (c *Client) Tokens(ctx context.Context) (*Tokens, error) {
+	return c.tokens.Load(ctx)
+}
+
+// do sends a JSON request and decodes the JSON response into out, which may
+// be nil. Authenticated calls send the stored access token and refresh it
+// once on 401.
+func (c *Client) do(ctx context.Context, method, path string, in, out any, authenticated bool) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	var tokens *Tokens
+	if authenticated {
+		var err error
+		if tokens, err = c.tokens.Load(ctx); err != nil {
+			return err
+		}
+		if tokens == nil || tokens.AccessToken == "" {
+			return ErrNotAuthenticated
+		}
+	}
+
+	resp, err := c.send(ctx, method, path, body, tokens)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && tokens != nil && tokens.RefreshToken != "" {
+		resp.Body.Close()
+
+		if tokens, err = c.refreshAfter(ctx, tokens); err != nil {
+			return err
+		}
+		if resp, err = c.send(ctx, method, path, body, tokens); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	return decodeResponse(resp, out)
+}
+
+// send performs the request, retrying idempotent methods.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, tokens *Tokens) (*http.Response, error) {
+	attempts := 1
+	if isIdempotent(method) {
+		attempts = c.retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+		if tokens != nil {
+			req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if attempt >= attempts || !retryable(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay of up to BaseDelay*2^(attempt-1), capped at
+// MaxDelay, or the Retry-After of the response if it is shorter than MaxDelay.
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if delay := time.Duration(seconds) * time.Second; delay <= c.retry.MaxDelay {
+				return delay
+			}
+		}
+	}
+
+	ceiling := c.retry.BaseDelay << (attempt - 1)
+	if ceiling > c.retry.MaxDelay || ceiling <= 0 {
+		ceiling = c.retry.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// refreshAfter refreshes the tokens unless another call already replaced
+// stale while this one waited for the lock.
+func (c *Client) refreshAfter(ctx context.Context, stale *Tokens) (*Tokens, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	current, err := c.tokens.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrNotAuthenticated
+	}
+	if current.AccessToken != stale.AccessToken {
+		return current, nil
+	}
+
+	if _, err := c.refresh(ctx, current.RefreshToken); err != nil {
+		return nil, err
+	}
+	return c.tokens.Load(ctx)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Code == "" {
+			apiErr.Code = strings.ToLower(strings.ReplaceAll(http.StatusText(resp.StatusCode), " ", "_"))
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,359 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	userv1 "github.com/zhanserikAmangeldi/user-service/pkg/pb/user/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeServer imitates the auth endpoints of user-service. Only the latest
+// access token is accepted, and each refresh token can be used once.
+type fakeServer struct {
+	mu           sync.Mutex
+	generation   int
+	accessToken  string
+	refreshToken string
+	refreshes    atomic.Int32
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeServer{}
+	f.rotate()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req LoginRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Password != "secret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_credentials", "message": "Invalid login or password"})
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		writeJSON(w, http.StatusOK, f.authResponse())
+	})
+	mux.HandleFunc("POST /api/v1/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		f.refreshes.Add(1)
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if req["refresh_token"] != f.refreshToken {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+			return
+		}
+		f.rotate()
+		writeJSON(w, http.StatusOK, f.authResponse())
+	})
+	mux.HandleFunc("GET /api/v1/users/me", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		valid := r.Header.Get("Authorization") == "Bearer "+f.accessToken
+		f.mu.Unlock()
+		if !valid {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired token"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &User{ID: 1, Username: "alice"})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+// rotate issues new tokens. f.mu must be held.
+func (f *fakeServer) rotate() {
+	f.generation++
+	f.accessToken = "access-" + strconv.Itoa(f.generation)
+	f.refreshToken = "refresh-" + strconv.Itoa(f.generation)
+}
+
+func (f *fakeServer) authResponse() *AuthResponse {
+	return &AuthResponse{
+		AccessToken:  f.accessToken,
+		RefreshToken: f.refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    900,
+		User:         &User{ID: 1, Username: "alice"},
+	}
+}
+
+// expire makes the current access token invalid without rotating the
+// refresh token, as if it had expired.
+func (f *fakeServer) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accessToken = "expired"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestLogin_StoresTokens(t *testing.T) {
+	_, server := newFakeServer(t)
+	c := New(server.URL)
+	ctx := context.Background()
+
+	if _, err := c.Me(ctx); !errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("expected ErrNotAuthenticated before login, got %v", err)
+	}
+
+	resp, err := c.Login(ctx, &LoginRequest{Login: "alice", Password: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+	if resp.User == nil || resp.User.Username != "alice" {
+		t.Errorf("unexpected user %+v", resp.User)
+	}
+
+	tokens, _ := c.Tokens(ctx)
+	if tokens == nil || tokens.AccessToken != "access-1" || tokens.RefreshToken != "refresh-1" {
+		t.Fatalf("expected stored tokens, got %+v", tokens)
+	}
+
+	me, err := c.Me(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if me.ID != 1 {
+		t.Errorf("expected user 1, got %d", me.ID)
+	}
+}
+
+func TestLogin_APIError(t *testing.T) {
+	_, server := newFakeServer(t)
+	c := New(server.URL)
+
+	_, err := c.Login(context.Background(), &LoginRequest{Login: "alice", Password: "wrong"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != "invalid_credentials" || apiErr.Message == "" {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	f, server := newFakeServer(t)
+	c := New(server.URL)
+	ctx := context.Background()
+
+	if _, err := c.Login(ctx, &LoginRequest{Login: "alice", Password: "secret"}); err != nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+	f.expire()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Me(ctx)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected the call to succeed after a refresh, got %v", err)
+		}
+	}
+	if n := f.refreshes.Load(); n != 1 {
+		t.Errorf("expected concurrent calls to share one refresh, got %d", n)
+	}
+
+	tokens, _ := c.Tokens(ctx)
+	if tokens.AccessToken != "access-2" || tokens.RefreshToken != "refresh-2" {
+		t.Errorf("expected rotated tokens, got %+v", tokens)
+	}
+}
+
+func TestRefreshFailure_ClearsTokens(t *testing.T) {
+	f, server := newFakeServer(t)
+	store := NewMemoryStore()
+	c := New(server.URL, WithTokenStore(store))
+	ctx := context.Background()
+
+	_ = store.Save(ctx, &Tokens{AccessToken: "stale", RefreshToken: "revoked"})
+	f.expire()
+
+	_, err := c.Me(ctx)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 from the refresh, got %v", err)
+	}
+	if tokens, _ := store.Load(ctx); tokens != nil {
+		t.Errorf("expected tokens to be cleared, got %+v", tokens)
+	}
+}
+
+func TestRetry_IdempotentCalls(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "unavailable"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &User{ID: 7})
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore()
+	_ = store.Save(context.Background(), &Tokens{AccessToken: "token"})
+	c := New(server.URL, WithTokenStore(store), WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}))
+
+	user, err := c.GetUser(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if user.ID != 7 || calls.Load() != 3 {
+		t.Errorf("expected user 7 after 3 attempts, got user %d after %d", user.ID, calls.Load())
+	}
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "bad_gateway"})
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore()
+	_ = store.Save(context.Background(), &Tokens{AccessToken: "token"})
+	c := New(server.URL, WithTokenStore(store), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+
+	_, err := c.Me(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the last 502, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestRetry_NotForPost(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "unavailable"})
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+
+	if _, err := c.Login(context.Background(), &LoginRequest{Login: "alice", Password: "secret"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a POST not to be retried, got %d attempts", calls.Load())
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != "chat-service" || secret != "s3cret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "users:read" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &ServiceToken{AccessToken: "service", TokenType: "Bearer", ExpiresIn: 900, Scope: "users:read"})
+	}))
+	defer server.Close()
+
+	token, err := New(server.URL).ClientCredentials(context.Background(), "chat-service", "s3cret", "users:read")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token.AccessToken != "service" || token.Scope != "users:read" {
+		t.Errorf("unexpected token %+v", token)
+	}
+}
+
+type fakeUserService struct {
+	userv1.UnimplementedUserServiceServer
+}
+
+func (fakeUserService) GetUser(_ context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	return &userv1.GetUserResponse{User: &userv1.User{Id: req.GetUserId(), Username: "grpc-user", DisplayName: "Grpc"}}, nil
+}
+
+func (fakeUserService) BatchGetUsers(_ context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	resp := &userv1.BatchGetUsersResponse{}
+	for _, id := range req.GetUserIds() {
+		resp.Users = append(resp.Users, &userv1.User{Id: id})
+	}
+	return resp, nil
+}
+
+func TestGRPCTransport(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	userv1.RegisterUserServiceServer(server, fakeUserService{})
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected dial error: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+
+	if _, err := New("http://unused").BatchGetUsers(ctx, []int64{1}); !errors.Is(err, ErrNoGRPC) {
+		t.Errorf("expected ErrNoGRPC without a connection, got %v", err)
+	}
+
+	c := New("http://unused", WithGRPC(conn))
+
+	user, err := c.GetUser(ctx, 42)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID != 42 || user.Username != "grpc-user" || user.DisplayName == nil || *user.DisplayName != "Grpc" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	users, err := c.BatchGetUsers(ctx, []int64{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(users) != 3 {
+		t.Errorf("expected 3 users, got %d", len(users))
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	userv1 "github.com/zhanserikAmangeldi/user-service/pkg/pb/user/v1"
+)
+
+// BatchGetUsers returns the users among userIDs that exist. It needs a gRPC
+// connection.
+func (c *Client) BatchGetUsers(ctx context.Context, userIDs []int64) ([]*User, error) {
+	if c.users == nil {
+		return nil, ErrNoGRPC
+	}
+
+	resp, err := c.users.BatchGetUsers(ctx, &userv1.BatchGetUsersRequest{UserIds: userIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0, len(resp.GetUsers()))
+	for _, user := range resp.GetUsers() {
+		users = append(users, fromProtoUser(user))
+	}
+	return users, nil
+}
+
+// GetUserByUsername needs a gRPC connection.
+func (c *Client) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	if c.users == nil {
+		return nil, ErrNoGRPC
+	}
+
+	resp, err := c.users.GetUserByUsername(ctx, &userv1.GetUserByUsernameRequest{Username: username})
+	if err != nil {
+		return nil, err
+	}
+	return fromProtoUser(resp.GetUser()), nil
+}
+
+// ValidateToken checks a user's access token, including revocation. It needs
+// a gRPC connection.
+func (c *Client) ValidateToken(ctx context.Context, accessToken string) (*TokenClaims, error) {
+	if c.users == nil {
+		return nil, ErrNoGRPC
+	}
+
+	resp, err := c.users.ValidateToken(ctx, &userv1.ValidateTokenRequest{AccessToken: accessToken})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenClaims{
+		UserID:         resp.GetUserId(),
+		Username:       resp.GetUsername(),
+		Email:          resp.GetEmail(),
+		SessionID:      resp.GetSessionId(),
+		ImpersonatorID: resp.GetImpersonatorId(),
+		DPoPThumbprint: resp.GetDpopJkt(),
+		ExpiresAt:      resp.GetExpiresAt().AsTime(),
+	}, nil
+}
+
+func (c *Client) grpcGetUser(ctx context.Context, userID int64) (*User, error) {
+	resp, err := c.users.GetUser(ctx, &userv1.GetUserRequest{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+	return fromProtoUser(resp.GetUser()), nil
+}
+
+func fromProtoUser(pb *userv1.User) *User {
+	user := &User{
+		ID:         pb.GetId(),
+		Username:   pb.GetUsername(),
+		Email:      pb.GetEmail(),
+		Status:     pb.GetStatus(),
+		Role:       pb.GetRole(),
+		IsVerified: pb.GetIsVerified(),
+		CreatedAt:  pb.GetCreatedAt().AsTime(),
+	}
+	if v := pb.GetDisplayName(); v != "" {
+		user.DisplayName = &v
+	}
+	if v := pb.GetAvatarUrl(); v != "" {
+		user.AvatarURL = &v
+	}
+	if v := pb.GetBio(); v != "" {
+		user.Bio = &v
+	}
+	if pb.GetLastSeenAt() != nil {
+		lastSeen := pb.GetLastSeenAt().AsTime()
+		user.LastSeenAt = &lastSeen
+	}
+	return user
+}
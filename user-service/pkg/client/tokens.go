@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tokens are the credentials of the signed-in user.
+type Tokens struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// TokenStore keeps the tokens between calls, e.g. in memory, a file or the
+// OS keychain. Load returns nil tokens when nobody is signed in. A store
+// shared by several clients must be safe for concurrent use.
+type TokenStore interface {
+	Load(ctx context.Context) (*Tokens, error)
+	Save(ctx context.Context, tokens *Tokens) error
+	Clear(ctx context.Context) error
+}
+
+// MemoryStore is the default TokenStore; tokens are lost when the process
+// exits.
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens *Tokens
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Load(context.Context) (*Tokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokens == nil {
+		return nil, nil
+	}
+	tokens := *s.tokens
+	return &tokens, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, tokens *Tokens) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *tokens
+	s.tokens = &saved
+	return nil
+}
+
+func (s *MemoryStore) Clear(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = nil
+	return nil
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// The request and response types mirror the JSON of the internal dto and
+// models packages, which other modules cannot import.
+
+type RegisterRequest struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name,omitempty"`
+	DeviceName  string `json:"device_name,omitempty"`
+}
+
+type LoginRequest struct {
+	// Login is an email or a username.
+	Login      string `json:"login"`
+	Password   string `json:"password"`
+	DeviceName string `json:"device_name,omitempty"`
+}
+
+type AuthResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	User         *User  `json:"user"`
+}
+
+type User struct {
+	ID          int64   `json:"id"`
+	Username    string  `json:"username"`
+	Email       string  `json:"email"`
+	DisplayName *string `json:"display_name,omitempty"`
+	AvatarURL   *string `json:"avatar_url,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	Status      string  `json:"status"`
+	// PresenceOverride is only returned for the signed-in user.
+	PresenceOverride *string    `json:"presence_override,omitempty"`
+	Role             string     `json:"role"`
+	IsVerified       bool       `json:"is_verified"`
+	LastSeenAt       *time.Time `json:"last_seen_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// UpdateUserRequest changes only the fields that are set.
+type UpdateUserRequest struct {
+	DisplayName *string `json:"display_name,omitempty"`
+	AvatarURL   *string `json:"avatar_url,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	// PresenceOverride is busy, invisible, or none to clear the override.
+	PresenceOverride *string `json:"presence_override,omitempty"`
+}
+
+// Presence is the status of the signed-in user after a heartbeat.
+type Presence struct {
+	Status string `json:"status"`
+	// HeartbeatInterval is how often, in seconds, to send heartbeats.
+	HeartbeatInterval int64 `json:"heartbeat_interval"`
+}
+
+type Session struct {
+	ID                int64     `json:"id"`
+	DeviceName        *string   `json:"device_name,omitempty"`
+	DeviceType        string    `json:"device_type"`
+	Browser           *string   `json:"browser,omitempty"`
+	OS                *string   `json:"os,omitempty"`
+	UserAgent         *string   `json:"user_agent,omitempty"`
+	IPAddress         *string   `json:"ip_address,omitempty"`
+	FirstIPAddress    *string   `json:"first_ip_address,omitempty"`
+	Location          *string   `json:"location,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastActiveAt      time.Time `json:"last_active_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	IsCurrent         bool      `json:"is_current"`
+}
+
+type SessionList struct {
+	Sessions []*Session `json:"sessions"`
+	Total    int        `json:"total"`
+}
+
+// ServiceToken is issued to a service client by the client credentials grant.
+type ServiceToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// TokenClaims describes a user's access token validated over gRPC.
+type TokenClaims struct {
+	UserID         int64
+	Username       string
+	Email          string
+	SessionID      int64
+	ImpersonatorID int64
+	DPoPThumbprint string
+	ExpiresAt      time.Time
+}
+
+// APIError is an error response of user-service.
+type APIError struct {
+	StatusCode int
+	Code       string `json:"error"`
+	Message    string `json:"message,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("user-service: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("user-service: %d %s", e.StatusCode, e.Code)
+}
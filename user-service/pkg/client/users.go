@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	user := &User{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/users/me", nil, user, true); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (c *Client) UpdateMe(ctx context.Context, req *UpdateUserRequest) (*User, error) {
+	user := &User{}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/users/me", req, user, true); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUser returns a user's profile, over gRPC if the client has a connection
+// and otherwise over HTTP on behalf of the signed-in user.
+func (c *Client) GetUser(ctx context.Context, userID int64) (*User, error) {
+	if c.users != nil {
+		return c.grpcGetUser(ctx, userID)
+	}
+
+	user := &User{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/users/"+strconv.FormatInt(userID, 10), nil, user, true); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Heartbeat keeps connectionID of the signed-in user up. Send it every
+// HeartbeatInterval seconds, with active set if the user did anything since
+// the last one.
+func (c *Client) Heartbeat(ctx context.Context, connectionID string, active bool) (*Presence, error) {
+	req := map[string]any{"connection_id": connectionID, "active": active}
+	presence := &Presence{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/presence/heartbeat", req, presence, true); err != nil {
+		return nil, err
+	}
+	return presence, nil
+}
+
+// Disconnect drops connectionID at once instead of letting it expire.
+func (c *Client) Disconnect(ctx context.Context, connectionID string) error {
+	req := map[string]string{"connection_id": connectionID}
+	return c.do(ctx, http.MethodPost, "/api/v1/presence/disconnect", req, nil, true)
+}
@@ -0,0 +1,180 @@
+// Package dpop verifies RFC 9449 DPoP proofs: JWTs signed by a key the client
+// holds, which bind a request to that key. Tokens issued to the client carry
+// the key's thumbprint in their cnf.jkt claim, so a leaked token is useless
+// without the private key.
+//
+// Proof IDs are remembered in Redis under
+//
+//	dpop_jti:<hash of thumbprint and jti>
+//
+// for as long as the proof could be accepted, which stops replays.
+package dpop
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HeaderName is the request header that carries the proof.
+const HeaderName = "DPoP"
+
+const proofType = "dpop+jwt"
+
+var (
+	ErrMissingProof  = errors.New("missing DPoP proof")
+	ErrInvalidProof  = errors.New("invalid DPoP proof")
+	ErrReplayedProof = errors.New("DPoP proof has already been used")
+	// ErrUnavailable is returned together with a verified Proof when the replay
+	// check could not be made.
+	ErrUnavailable = errors.New("dpop replay cache unavailable")
+)
+
+var signingMethods = []string{"ES256", "ES384", "ES512", "EdDSA", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+
+// Proof is a verified DPoP proof.
+type Proof struct {
+	// Thumbprint is the RFC 7638 thumbprint of the proof key, the value of
+	// cnf.jkt in tokens bound to it.
+	Thumbprint string
+	ID         string
+	IssuedAt   time.Time
+}
+
+type proofClaims struct {
+	Method          string `json:"htm"`
+	URL             string `json:"htu"`
+	AccessTokenHash string `json:"ath,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier accepts proofs issued at most maxAge before or after the current
+// time and rejects any proof it has already seen.
+type Verifier struct {
+	client redis.UniversalClient
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+func NewVerifier(client redis.UniversalClient, maxAge time.Duration) *Verifier {
+	return &Verifier{client: client, maxAge: maxAge, now: time.Now}
+}
+
+func ReplayKey(thumbprint, jti string) string {
+	sum := sha256.Sum256([]byte(thumbprint + ":" + jti))
+	return "dpop_jti:" + hex.EncodeToString(sum[:])
+}
+
+// AccessTokenHash returns the ath claim a proof must carry when presented
+// with accessToken.
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Verify checks proof against a request with the given method and URL. If
+// accessToken is set, the proof must also be bound to it through its ath
+// claim. When Redis cannot be reached the proof is returned along with
+// ErrUnavailable and the caller decides whether to accept it.
+func (v *Verifier) Verify(ctx context.Context, proof, method, requestURL, accessToken string) (*Proof, error) {
+	if proof == "" {
+		return nil, ErrMissingProof
+	}
+
+	var keyThumbprint string
+	parser := jwt.NewParser(jwt.WithValidMethods(signingMethods), jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(proof, &proofClaims{}, func(token *jwt.Token) (any, error) {
+		if typ, _ := token.Header["typ"].(string); typ != proofType {
+			return nil, fmt.Errorf("typ must be %s", proofType)
+		}
+		raw, ok := token.Header["jwk"].(map[string]any)
+		if !ok {
+			return nil, errors.New("missing jwk header")
+		}
+		key, thumb, err := parseJWK(raw)
+		if err != nil {
+			return nil, err
+		}
+		keyThumbprint = thumb
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	claims := token.Claims.(*proofClaims)
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("%w: jti and iat are required", ErrInvalidProof)
+	}
+	if claims.Method != method {
+		return nil, fmt.Errorf("%w: htm does not match the request", ErrInvalidProof)
+	}
+	if !sameURL(claims.URL, requestURL) {
+		return nil, fmt.Errorf("%w: htu does not match the request", ErrInvalidProof)
+	}
+	if accessToken != "" && claims.AccessTokenHash != AccessTokenHash(accessToken) {
+		return nil, fmt.Errorf("%w: ath does not match the access token", ErrInvalidProof)
+	}
+
+	issuedAt := claims.IssuedAt.Time
+	if age := v.now().Sub(issuedAt); age > v.maxAge || age < -v.maxAge {
+		return nil, fmt.Errorf("%w: iat is outside the accepted window", ErrInvalidProof)
+	}
+
+	result := &Proof{Thumbprint: keyThumbprint, ID: claims.ID, IssuedAt: issuedAt}
+
+	// A proof is accepted until maxAge after its iat, which is at most 2*maxAge from now.
+	fresh, err := v.client.SetNX(ctx, ReplayKey(keyThumbprint, claims.ID), "1", 2*v.maxAge).Result()
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if !fresh {
+		return nil, ErrReplayedProof
+	}
+
+	return result, nil
+}
+
+// sameURL compares htu with the request URL as RFC 9449 asks: without query
+// and fragment, with scheme and host compared case-insensitively.
+func sameURL(htu, requestURL string) bool {
+	a, err := normalizeURL(htu)
+	if err != nil {
+		return false
+	}
+	b, err := normalizeURL(requestURL)
+	if err != nil {
+		return false
+	}
+	return a == b
+}
+
+func normalizeURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("absolute URL required")
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if (scheme == "https" && strings.HasSuffix(host, ":443")) || (scheme == "http" && strings.HasSuffix(host, ":80")) {
+		host = host[:strings.LastIndex(host, ":")]
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path, nil
+}
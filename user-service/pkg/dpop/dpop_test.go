@@ -0,0 +1,212 @@
+package dpop
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+const testURL = "https://api.example.com/api/v1/auth/refresh"
+
+func newTestVerifier(t *testing.T) (*miniredis.Miniredis, *Verifier) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, NewVerifier(client, time.Minute)
+}
+
+func ecJWK(key *ecdsa.PrivateKey) map[string]any {
+	b64 := base64.RawURLEncoding.EncodeToString
+	return map[string]any{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   b64(key.X.FillBytes(make([]byte, 32))),
+		"y":   b64(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func signProof(t *testing.T, key *ecdsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = proofType
+	token.Header["jwk"] = ecJWK(key)
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign proof: %v", err)
+	}
+	return signed
+}
+
+func proofClaimsFor(method, htu string) jwt.MapClaims {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return jwt.MapClaims{
+		"jti": base64.RawURLEncoding.EncodeToString(b),
+		"htm": method,
+		"htu": htu,
+		"iat": time.Now().Unix(),
+	}
+}
+
+func TestVerify_ValidProofAndReplay(t *testing.T) {
+	_, verifier := newTestVerifier(t)
+	ctx := context.Background()
+	key := newECKey(t)
+	proof := signProof(t, key, proofClaimsFor("POST", testURL))
+
+	result, err := verifier.Verify(ctx, proof, "POST", testURL+"?ignored=1", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, expected, err := parseJWK(ecJWK(key))
+	if err != nil {
+		t.Fatalf("parse jwk: %v", err)
+	}
+	if result.Thumbprint != expected {
+		t.Errorf("expected thumbprint %s, got %s", expected, result.Thumbprint)
+	}
+
+	if _, err := verifier.Verify(ctx, proof, "POST", testURL, ""); !errors.Is(err, ErrReplayedProof) {
+		t.Errorf("expected ErrReplayedProof, got %v", err)
+	}
+}
+
+func TestVerify_RequestMismatch(t *testing.T) {
+	_, verifier := newTestVerifier(t)
+	ctx := context.Background()
+	key := newECKey(t)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+	}{
+		{"method", "GET", testURL},
+		{"path", "POST", "https://api.example.com/api/v1/auth/logout"},
+		{"host", "POST", "https://evil.example.com/api/v1/auth/refresh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof := signProof(t, key, proofClaimsFor("POST", testURL))
+			if _, err := verifier.Verify(ctx, proof, tt.method, tt.url, ""); !errors.Is(err, ErrInvalidProof) {
+				t.Errorf("expected ErrInvalidProof, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerify_AccessTokenHash(t *testing.T) {
+	_, verifier := newTestVerifier(t)
+	ctx := context.Background()
+	key := newECKey(t)
+
+	claims := proofClaimsFor("GET", testURL)
+	claims["ath"] = AccessTokenHash("access-token")
+	if _, err := verifier.Verify(ctx, signProof(t, key, claims), "GET", testURL, "other-token"); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expected ErrInvalidProof for a different token, got %v", err)
+	}
+
+	claims = proofClaimsFor("GET", testURL)
+	claims["ath"] = AccessTokenHash("access-token")
+	if _, err := verifier.Verify(ctx, signProof(t, key, claims), "GET", testURL, "access-token"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestVerify_StaleProof(t *testing.T) {
+	_, verifier := newTestVerifier(t)
+	key := newECKey(t)
+
+	claims := proofClaimsFor("POST", testURL)
+	claims["iat"] = time.Now().Add(-5 * time.Minute).Unix()
+	if _, err := verifier.Verify(context.Background(), signProof(t, key, claims), "POST", testURL, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expected ErrInvalidProof, got %v", err)
+	}
+}
+
+func TestVerify_RejectsUnsupportedProofs(t *testing.T) {
+	_, verifier := newTestVerifier(t)
+	ctx := context.Background()
+	key := newECKey(t)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, proofClaimsFor("POST", testURL))
+	hmac.Header["typ"] = proofType
+	hmac.Header["jwk"] = map[string]any{"kty": "oct", "k": "c2VjcmV0"}
+	signed, _ := hmac.SignedString([]byte("secret"))
+	if _, err := verifier.Verify(ctx, signed, "POST", testURL, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expected ErrInvalidProof for an HMAC proof, got %v", err)
+	}
+
+	wrongType := jwt.NewWithClaims(jwt.SigningMethodES256, proofClaimsFor("POST", testURL))
+	wrongType.Header["typ"] = "JWT"
+	wrongType.Header["jwk"] = ecJWK(key)
+	signed, _ = wrongType.SignedString(key)
+	if _, err := verifier.Verify(ctx, signed, "POST", testURL, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expected ErrInvalidProof for a proof without typ dpop+jwt, got %v", err)
+	}
+
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	mismatched := jwt.NewWithClaims(jwt.SigningMethodEdDSA, proofClaimsFor("POST", testURL))
+	mismatched.Header["typ"] = proofType
+	mismatched.Header["jwk"] = ecJWK(key)
+	signed, _ = mismatched.SignedString(otherKey)
+	if _, err := verifier.Verify(ctx, signed, "POST", testURL, ""); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("expected ErrInvalidProof for a signature by another key, got %v", err)
+	}
+
+	if _, err := verifier.Verify(ctx, "", "POST", testURL, ""); !errors.Is(err, ErrMissingProof) {
+		t.Errorf("expected ErrMissingProof, got %v", err)
+	}
+}
+
+func TestVerify_Unavailable(t *testing.T) {
+	mr, verifier := newTestVerifier(t)
+	proof := signProof(t, newECKey(t), proofClaimsFor("POST", testURL))
+	mr.Close()
+
+	result, err := verifier.Verify(context.Background(), proof, "POST", testURL, "")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if result == nil || result.Thumbprint == "" {
+		t.Error("expected the verified proof to be returned with ErrUnavailable")
+	}
+}
+
+// TestThumbprint_RFC7638 checks the example from RFC 7638, section 3.1.
+func TestThumbprint_RFC7638(t *testing.T) {
+	_, thumb, err := parseJWK(map[string]any{
+		"kty": "RSA",
+		"n":   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		"e":   "AQAB",
+		"alg": "RS256",
+		"kid": "2011-04-29",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if thumb != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("unexpected thumbprint %s", thumb)
+	}
+}
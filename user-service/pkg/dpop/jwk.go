@@ -0,0 +1,132 @@
+package dpop
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// jwk holds the public members of the key types DPoP proofs may carry.
+type jwk struct {
+	Kty string
+	Crv string
+	X   string
+	Y   string
+	N   string
+	E   string
+	D   string
+}
+
+// parseJWK returns the public key described by the JWK in a proof header along
+// with its RFC 7638 thumbprint.
+func parseJWK(raw map[string]any) (crypto.PublicKey, string, error) {
+	str := func(name string) string {
+		s, _ := raw[name].(string)
+		return s
+	}
+	key := jwk{Kty: str("kty"), Crv: str("crv"), X: str("x"), Y: str("y"), N: str("n"), E: str("e"), D: str("d")}
+
+	if key.D != "" {
+		return nil, "", errors.New("jwk contains a private key")
+	}
+
+	switch key.Kty {
+	case "EC":
+		pub, err := key.ecPublicKey()
+		if err != nil {
+			return nil, "", err
+		}
+		return pub, thumbprint(fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, key.Crv, key.X, key.Y)), nil
+	case "OKP":
+		if key.Crv != "Ed25519" {
+			return nil, "", fmt.Errorf("unsupported OKP curve %q", key.Crv)
+		}
+		x, err := decodeMember(key.X, ed25519.PublicKeySize)
+		if err != nil {
+			return nil, "", err
+		}
+		return ed25519.PublicKey(x), thumbprint(fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, key.X)), nil
+	case "RSA":
+		n, err := decodeMember(key.N, 0)
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := decodeMember(key.E, 0)
+		if err != nil {
+			return nil, "", err
+		}
+		modulus := new(big.Int).SetBytes(n)
+		exponent := new(big.Int).SetBytes(e)
+		if modulus.BitLen() < 2048 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, "", errors.New("unacceptable RSA key")
+		}
+		pub := &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}
+		return pub, thumbprint(fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, key.E, key.N)), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %q", key.Kty)
+	}
+}
+
+func (k jwk) ecPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var checker ecdh.Curve
+	switch k.Crv {
+	case "P-256":
+		curve, checker = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, checker = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, checker = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	x, err := decodeMember(k.X, size)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeMember(k.Y, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// crypto/ecdh rejects points that are not on the curve.
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := checker.NewPublicKey(point); err != nil {
+		return nil, errors.New("EC point is not on the curve")
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
+
+// decodeMember decodes a base64url JWK member, checking its length if size is set.
+func decodeMember(value string, size int) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("malformed jwk member")
+	}
+	if size > 0 && len(b) != size {
+		return nil, errors.New("jwk member has the wrong length")
+	}
+	return b, nil
+}
+
+// thumbprint hashes the canonical JWK, whose members are known to be base64url
+// and therefore need no escaping.
+func thumbprint(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
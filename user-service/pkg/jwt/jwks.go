@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+)
+
+// JWK is an RFC 8037 Ed25519 public key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+}
+
+// JWKS is the JSON Web Key Set other services verify access tokens with.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWKS publishes the public halves of keys.
+func NewJWKS(keys []*SigningKey) *JWKS {
+	set := &JWKS{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.PublicKey),
+			Kid: key.ID,
+			Alg: "EdDSA",
+			Use: "sig",
+		})
+	}
+	return set
+}
+
+// PublicKeys returns the Ed25519 keys of the set by key ID. Keys of other
+// types are skipped, so a set may carry keys this package does not use.
+func (s *JWKS) PublicKeys() (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey, len(s.Keys))
+	for _, jwk := range s.Keys {
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize || jwk.Kid == "" {
+			return nil, ErrInvalidSigningKey
+		}
+		keys[jwk.Kid] = ed25519.PublicKey(x)
+	}
+	return keys, nil
+}
@@ -0,0 +1,49 @@
+package jwt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJWKS_RoundTrip(t *testing.T) {
+	first, _ := GenerateSigningKey()
+	second, _ := GenerateSigningKey()
+
+	data, err := json.Marshal(NewJWKS([]*SigningKey{first, second}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, err := set.PublicKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(keys) != 2 || !keys[first.ID].Equal(first.PublicKey) || !keys[second.ID].Equal(second.PublicKey) {
+		t.Errorf("keys did not survive the round trip: %v", keys)
+	}
+}
+
+func TestJWKS_SkipsOtherKeyTypes(t *testing.T) {
+	set := JWKS{Keys: []JWK{{Kty: "RSA", Kid: "rsa", X: "ignored"}}}
+
+	keys, err := set.PublicKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+}
+
+func TestJWKS_RejectsMalformedKey(t *testing.T) {
+	set := JWKS{Keys: []JWK{{Kty: "OKP", Crv: "Ed25519", Kid: "short", X: "AAAA"}}}
+
+	if _, err := set.PublicKeys(); err != ErrInvalidSigningKey {
+		t.Errorf("expected ErrInvalidSigningKey, got %v", err)
+	}
+}
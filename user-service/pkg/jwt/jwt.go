@@ -1,47 +1,201 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+func init() {
+	// Revocation compares "iat" with the user's not-before time in
+	// milliseconds, so a token issued in the same second as a revocation is
+	// still told apart.
+	jwt.TimePrecision = time.Millisecond
+}
+
+// AccessTokenTTL is the default lifetime of an access token.
+const AccessTokenTTL = 15 * time.Minute
+
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+// Token types, carried in the "typ" claim. Only access tokens are accepted
+// as bearer tokens.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("expired token")
 )
 
 type Claims struct {
-	UserId   int64  `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	TokenType string      `json:"typ,omitempty"`
+	UserId    int64       `json:"user_id"`
+	Username  string      `json:"username"`
+	Email     string      `json:"email"`
+	Act       *ActorClaim `json:"act,omitempty"`
+	// SessionID ties an access token to its row in the sessions table so that
+	// revoking the session revokes the token.
+	SessionID int64 `json:"sid,omitempty"`
+	// Cnf binds the token to the DPoP key of the client it was issued to.
+	Cnf *Confirmation `json:"cnf,omitempty"`
+	// ClientID and Scope are set on service tokens, which are issued to other
+	// services rather than users and carry no user claims.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Confirmation is the RFC 7800 "cnf" claim, holding the RFC 9449 thumbprint
+// of the key a DPoP-bound token is bound to.
+type Confirmation struct {
+	JKT string `json:"jkt"`
+}
+
+// ActorClaim is the RFC 8693 "act" claim. It identifies who is acting on
+// behalf of the subject of the token.
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
+
+// IsImpersonated reports whether the token was issued to someone acting as the user.
+func (c *Claims) IsImpersonated() bool {
+	return c.Act != nil
+}
+
+// KeyThumbprint returns the DPoP key thumbprint the token is bound to, or ""
+// for a plain bearer token.
+func (c *Claims) KeyThumbprint() string {
+	if c.Cnf == nil {
+		return ""
+	}
+	return c.Cnf.JKT
+}
+
+// IsAccessToken reports whether the token may be used as a bearer token.
+func (c *Claims) IsAccessToken() bool {
+	return c.TokenType == TokenTypeAccess
+}
+
+// IsService reports whether the token was issued to a service client.
+func (c *Claims) IsService() bool {
+	return c.ClientID != ""
+}
+
+// HasScope reports whether the space-separated "scope" claim contains scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ActorID returns the user ID of the acting party, or 0 if there is none.
+func (c *Claims) ActorID() int64 {
+	if c.Act == nil {
+		return 0
+	}
+	id, err := strconv.ParseInt(c.Act.Subject, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+type tokenOptions struct {
+	ttl       time.Duration
+	actorID   int64
+	sessionID int64
+	jkt       string
+}
+
+type TokenOption func(*tokenOptions)
+
+// WithTTL overrides the default lifetime of a token.
+func WithTTL(ttl time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithActor adds an "act" claim naming the user who acts on behalf of the subject.
+func WithActor(actorID int64) TokenOption {
+	return func(o *tokenOptions) {
+		o.actorID = actorID
+	}
+}
+
+// WithSessionID adds a "sid" claim naming the session the token belongs to.
+func WithSessionID(sessionID int64) TokenOption {
+	return func(o *tokenOptions) {
+		o.sessionID = sessionID
+	}
+}
+
+// WithKeyThumbprint binds the token to a DPoP key through a "cnf" claim.
+func WithKeyThumbprint(jkt string) TokenOption {
+	return func(o *tokenOptions) {
+		o.jkt = jkt
+	}
+}
+
+func (o *tokenOptions) confirmation() *Confirmation {
+	if o.jkt == "" {
+		return nil
+	}
+	return &Confirmation{JKT: o.jkt}
+}
+
+// TokenManager signs tokens with the active Ed25519 key when one has been
+// installed with SetSigningKeys, and with the shared HMAC secret otherwise.
+// Tokens signed with the secret stay valid either way.
 type TokenManager struct {
 	secretKey string
+
+	mu        sync.RWMutex
+	activeKey *SigningKey
+	keys      map[string]*SigningKey
 }
 
 func NewTokenManager(secretKey string) *TokenManager {
 	return &TokenManager{secretKey: secretKey}
 }
 
-func (tm *TokenManager) GenerateAccessToken(userId int64, username, email string) (string, time.Time, error) {
-	expiresAt := time.Now().Add(time.Minute * 15)
+func (tm *TokenManager) GenerateAccessToken(userId int64, username, email string, opts ...TokenOption) (string, time.Time, error) {
+	options := tokenOptions{ttl: AccessTokenTTL}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	expiresAt := time.Now().Add(options.ttl)
 
 	claims := Claims{
-		UserId:   userId,
-		Username: username,
-		Email:    email,
+		TokenType: TokenTypeAccess,
+		UserId:    userId,
+		Username:  username,
+		Email:     email,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if options.actorID != 0 {
+		claims.Act = &ActorClaim{Subject: strconv.FormatInt(options.actorID, 10)}
+	}
+	claims.SessionID = options.sessionID
+	claims.Cnf = options.confirmation()
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(tm.secretKey))
+	tokenString, err := tm.sign(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -49,21 +203,35 @@ func (tm *TokenManager) GenerateAccessToken(userId int64, username, email string
 	return tokenString, expiresAt, nil
 }
 
-func (tm *TokenManager) GenerateRefreshToken(userID int64, username, email string) (string, time.Time, error) {
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+// GenerateServiceToken issues an access token to a service client. Its
+// subject is the client ID and it is valid for scopes only.
+func (tm *TokenManager) GenerateServiceToken(clientID string, scopes []string, opts ...TokenOption) (string, time.Time, error) {
+	options := tokenOptions{ttl: AccessTokenTTL}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(options.ttl)
 
 	claims := Claims{
-		UserId:   userID,
-		Username: username,
-		Email:    email,
+		TokenType: TokenTypeAccess,
+		ClientID:  clientID,
+		Scope:     strings.Join(scopes, " "),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   clientID,
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(tm.secretKey))
+	tokenString, err := tm.sign(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -71,13 +239,80 @@ func (tm *TokenManager) GenerateRefreshToken(userID int64, username, email strin
 	return tokenString, expiresAt, nil
 }
 
-func (tm *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+// GenerateRefreshToken issues a refresh token. Every token carries a random
+// jti, so two tokens issued to the same user within a second still differ.
+func (tm *TokenManager) GenerateRefreshToken(userID int64, username, email string, opts ...TokenOption) (string, time.Time, error) {
+	options := tokenOptions{ttl: refreshTokenTTL}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(options.ttl)
+
+	claims := Claims{
+		TokenType: TokenTypeRefresh,
+		UserId:    userID,
+		Username:  username,
+		Email:     email,
+		SessionID: options.sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Cnf: options.confirmation(),
+	}
+
+	tokenString, err := tm.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+func (tm *TokenManager) sign(claims Claims) (string, error) {
+	tm.mu.RLock()
+	key := tm.activeKey
+	tm.mu.RUnlock()
+
+	if key == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(tm.secretKey))
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+func (tm *TokenManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return []byte(tm.secretKey), nil
+	case *jwt.SigningMethodEd25519:
+		kid, _ := token.Header["kid"].(string)
+
+		tm.mu.RLock()
+		key, ok := tm.keys[kid]
+		tm.mu.RUnlock()
+
+		if !ok {
 			return nil, ErrInvalidToken
 		}
-		return []byte(tm.secretKey), nil
-	})
+		return key.PublicKey, nil
+	default:
+		return nil, ErrInvalidToken
+	}
+}
+
+func (tm *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, tm.keyFunc)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -93,3 +328,24 @@ func (tm *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ValidateAccessToken is ValidateToken restricted to access tokens, so that a
+// refresh token cannot be presented as a bearer token.
+func (tm *TokenManager) ValidateAccessToken(tokenString string) (*Claims, error) {
+	claims, err := tm.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.IsAccessToken() {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
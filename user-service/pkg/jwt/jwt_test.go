@@ -66,6 +66,27 @@ func TestGenerateAndValidateRefreshToken(t *testing.T) {
 	}
 }
 
+func TestGenerateRefreshToken_WithTTL(t *testing.T) {
+	manager := NewTokenManager("slidingsecret")
+
+	first, expiresAt, err := manager.GenerateRefreshToken(7, "user", "user@example.com", WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedExp := time.Now().Add(time.Hour)
+	if expiresAt.Sub(expectedExp) > 2*time.Second || expectedExp.Sub(expiresAt) > 2*time.Second {
+		t.Errorf("expected expiry around %v, got %v", expectedExp, expiresAt)
+	}
+
+	second, _, err := manager.GenerateRefreshToken(7, "user", "user@example.com", WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first == second {
+		t.Error("expected refresh tokens issued in the same second to differ")
+	}
+}
+
 func TestValidateToken_InvalidSignature(t *testing.T) {
 	manager := NewTokenManager("secret1")
 	managerWrong := NewTokenManager("wrongsecret")
@@ -133,3 +154,162 @@ func TestValidateToken_InvalidFormat(t *testing.T) {
 		t.Error("expected nil claims for invalid token string")
 	}
 }
+
+func TestGenerateAccessToken_WithActor(t *testing.T) {
+	manager := NewTokenManager("actorsecret")
+
+	tokenStr, expiresAt, err := manager.GenerateAccessToken(42, "target", "target@example.com",
+		WithActor(7), WithTTL(5*time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedExp := time.Now().Add(5 * time.Minute)
+	if expiresAt.Sub(expectedExp) > 2*time.Second {
+		t.Errorf("expected expiry around %v, got %v", expectedExp, expiresAt)
+	}
+
+	claims, err := manager.ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if !claims.IsImpersonated() {
+		t.Fatal("expected token to carry an act claim")
+	}
+	if claims.ActorID() != 7 {
+		t.Errorf("expected actor 7, got %d", claims.ActorID())
+	}
+	if claims.UserId != 42 {
+		t.Errorf("expected subject user 42, got %d", claims.UserId)
+	}
+}
+
+func TestGenerateAccessToken_WithoutActor(t *testing.T) {
+	manager := NewTokenManager("plainsecret")
+
+	tokenStr, _, err := manager.GenerateAccessToken(1, "user", "user@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := manager.ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if claims.IsImpersonated() || claims.ActorID() != 0 {
+		t.Errorf("expected no act claim, got %+v", claims.Act)
+	}
+}
+
+func TestGenerateAccessToken_WithSessionID(t *testing.T) {
+	manager := NewTokenManager("sessionsecret")
+
+	tokenStr, _, err := manager.GenerateAccessToken(3, "user", "user@example.com", WithSessionID(99))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := manager.ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if claims.SessionID != 99 {
+		t.Errorf("expected session 99, got %d", claims.SessionID)
+	}
+}
+
+func TestValidateAccessToken_RejectsRefreshToken(t *testing.T) {
+	manager := NewTokenManager("typesecret")
+
+	accessToken, _, err := manager.GenerateAccessToken(3, "user", "user@example.com", WithSessionID(99))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	refreshToken, _, err := manager.GenerateRefreshToken(3, "user", "user@example.com", WithSessionID(99))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := manager.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if claims.TokenType != TokenTypeAccess {
+		t.Errorf("expected token type %q, got %q", TokenTypeAccess, claims.TokenType)
+	}
+
+	if _, err := manager.ValidateAccessToken(refreshToken); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for a refresh token, got %v", err)
+	}
+
+	claims, err = manager.ValidateToken(refreshToken)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if claims.TokenType != TokenTypeRefresh || claims.SessionID != 99 {
+		t.Errorf("unexpected refresh claims: %+v", claims)
+	}
+}
+
+func TestGenerateTokens_WithKeyThumbprint(t *testing.T) {
+	manager := NewTokenManager("dpopsecret")
+
+	accessToken, _, err := manager.GenerateAccessToken(4, "user", "user@example.com", WithKeyThumbprint("thumb"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	refreshToken, _, err := manager.GenerateRefreshToken(4, "user", "user@example.com", WithKeyThumbprint("thumb"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tokenStr := range []string{accessToken, refreshToken} {
+		claims, err := manager.ValidateToken(tokenStr)
+		if err != nil {
+			t.Fatalf("unexpected validation error: %v", err)
+		}
+		if claims.KeyThumbprint() != "thumb" {
+			t.Errorf("expected cnf.jkt thumb, got %q", claims.KeyThumbprint())
+		}
+	}
+
+	plain, _, _ := manager.GenerateAccessToken(4, "user", "user@example.com")
+	claims, err := manager.ValidateToken(plain)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if claims.Cnf != nil {
+		t.Errorf("expected no cnf claim on a bearer token, got %+v", claims.Cnf)
+	}
+}
+
+func TestGenerateServiceToken(t *testing.T) {
+	manager := NewTokenManager("servicesecret")
+
+	tokenStr, _, err := manager.GenerateServiceToken("chat-service", []string{"users:read", "ws_tickets:validate"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := manager.ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if !claims.IsService() || claims.ClientID != "chat-service" || claims.Subject != "chat-service" {
+		t.Errorf("expected a service token for chat-service, got %+v", claims)
+	}
+	if claims.UserId != 0 {
+		t.Errorf("expected no user on a service token, got %d", claims.UserId)
+	}
+	if !claims.HasScope("ws_tickets:validate") || claims.HasScope("ws_tickets") {
+		t.Errorf("unexpected scopes %q", claims.Scope)
+	}
+
+	userToken, _, _ := manager.GenerateAccessToken(5, "user", "user@example.com")
+	claims, err = manager.ValidateToken(userToken)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if claims.IsService() {
+		t.Error("expected a user token not to be a service token")
+	}
+}
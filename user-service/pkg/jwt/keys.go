@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+)
+
+var ErrInvalidSigningKey = errors.New("invalid signing key")
+
+// SigningKey is an Ed25519 key identified by the "kid" token header.
+// Keys kept only to verify older tokens have no PrivateKey.
+type SigningKey struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+func GenerateSigningKey() (*SigningKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		ID:         hex.EncodeToString(id),
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}, nil
+}
+
+// PrivateKeyPEM encodes the private key as PKCS #8.
+func (k *SigningKey) PrivateKeyPEM() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseSigningKey decodes a PKCS #8 PEM private key produced by PrivateKeyPEM.
+func ParseSigningKey(id, privateKeyPEM string) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, ErrInvalidSigningKey
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidSigningKey
+	}
+
+	return &SigningKey{
+		ID:         id,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// SetSigningKeys replaces the key ring. New tokens are signed with active;
+// every key in keys, plus active, is accepted when validating tokens.
+// Passing a nil active key switches signing back to the HMAC secret.
+func (tm *TokenManager) SetSigningKeys(active *SigningKey, keys []*SigningKey) {
+	ring := make(map[string]*SigningKey, len(keys)+1)
+	for _, key := range keys {
+		ring[key.ID] = key
+	}
+	if active != nil {
+		ring[active.ID] = active
+	}
+
+	tm.mu.Lock()
+	tm.activeKey = active
+	tm.keys = ring
+	tm.mu.Unlock()
+}
+
+// VerificationKeys returns every public key currently accepted by ValidateToken.
+func (tm *TokenManager) VerificationKeys() []*SigningKey {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(tm.keys))
+	for _, key := range tm.keys {
+		keys = append(keys, &SigningKey{ID: key.ID, PublicKey: key.PublicKey})
+	}
+	return keys
+}
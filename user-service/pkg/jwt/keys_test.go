@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"testing"
+)
+
+func TestSigningKeys_SignAndValidate(t *testing.T) {
+	manager := NewTokenManager("fallbacksecret")
+
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("key generation failed: %v", err)
+	}
+	manager.SetSigningKeys(key, nil)
+
+	tokenStr, _, err := manager.GenerateAccessToken(10, "keyed", "keyed@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	claims, err := manager.ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if claims.UserId != 10 {
+		t.Errorf("claims mismatch: got %+v", claims)
+	}
+}
+
+func TestSigningKeys_Rotation(t *testing.T) {
+	manager := NewTokenManager("fallbacksecret")
+
+	legacyToken, _, err := manager.GenerateAccessToken(1, "legacy", "legacy@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	oldKey, _ := GenerateSigningKey()
+	manager.SetSigningKeys(oldKey, nil)
+	oldToken, _, err := manager.GenerateAccessToken(2, "old", "old@example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	newKey, _ := GenerateSigningKey()
+	manager.SetSigningKeys(newKey, []*SigningKey{oldKey})
+
+	for name, tokenStr := range map[string]string{"legacy": legacyToken, "old": oldToken} {
+		if _, err := manager.ValidateToken(tokenStr); err != nil {
+			t.Errorf("%s token should still validate, got %v", name, err)
+		}
+	}
+
+	manager.SetSigningKeys(newKey, nil)
+	if _, err := manager.ValidateToken(oldToken); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for retired key, got %v", err)
+	}
+}
+
+func TestSigningKeys_PEMRoundTrip(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("key generation failed: %v", err)
+	}
+
+	encoded, err := key.PrivateKeyPEM()
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+
+	parsed, err := ParseSigningKey(key.ID, encoded)
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+	if !parsed.PublicKey.Equal(key.PublicKey) {
+		t.Error("public key mismatch after round trip")
+	}
+
+	if _, err := ParseSigningKey("x", "not a pem"); err != ErrInvalidSigningKey {
+		t.Errorf("expected ErrInvalidSigningKey, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the cache; expired entries are swept once it is full.
+const maxCacheEntries = 100_000
+
+// Checker answers whether an access token has been revoked with a single MGET,
+// and optionally caches the answer per user and session for cacheTTL. A
+// revocation therefore takes up to cacheTTL to be seen by a Checker that has
+// already looked at the same session.
+type Checker struct {
+	client   redis.UniversalClient
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[cacheKey]*cacheEntry
+}
+
+type cacheKey struct {
+	userID    int64
+	sessionID int64
+}
+
+type cacheEntry struct {
+	sessionRevoked bool
+	notBefore      *string
+	suspended      *string
+	expiresAt      time.Time
+}
+
+// NewChecker returns a Checker. A zero cacheTTL disables caching.
+func NewChecker(client redis.UniversalClient, cacheTTL time.Duration) *Checker {
+	return &Checker{
+		client:   client,
+		cacheTTL: cacheTTL,
+		cache:    make(map[cacheKey]*cacheEntry),
+	}
+}
+
+// Check returns the revocation state of a token issued to userID for
+// sessionID at issuedAt. Tokens without a session (sessionID 0) are only
+// subject to the per-user checks. Redis errors are wrapped in ErrUnavailable.
+func (c *Checker) Check(ctx context.Context, userID, sessionID int64, issuedAt time.Time) (*Status, error) {
+	key := cacheKey{userID: userID, sessionID: sessionID}
+
+	if entry := c.cached(key); entry != nil {
+		return parseStatus(entry.sessionRevoked, entry.notBefore, entry.suspended, issuedAt), nil
+	}
+
+	keys := []string{NotBeforeKey(userID), SuspendedKey(userID)}
+	if sessionID != 0 {
+		keys = append(keys, SessionKey(sessionID))
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	entry := &cacheEntry{
+		notBefore: stringValue(values[0]),
+		suspended: stringValue(values[1]),
+	}
+	if sessionID != 0 {
+		entry.sessionRevoked = values[2] != nil
+	}
+	c.store(key, entry)
+
+	return parseStatus(entry.sessionRevoked, entry.notBefore, entry.suspended, issuedAt), nil
+}
+
+// Forget drops cached state of the user, so that revocations made by this
+// process take effect immediately.
+func (c *Checker) Forget(userID int64) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.cache {
+		if key.userID == userID {
+			delete(c.cache, key)
+		}
+	}
+}
+
+func (c *Checker) cached(key cacheKey) *cacheEntry {
+	if c.cacheTTL <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.cache, key)
+		return nil
+	}
+	return entry
+}
+
+func (c *Checker) store(key cacheKey, entry *cacheEntry) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+	entry.expiresAt = now.Add(c.cacheTTL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.cache) >= maxCacheEntries {
+		for k, e := range c.cache {
+			if now.After(e.expiresAt) {
+				delete(c.cache, k)
+			}
+		}
+		if len(c.cache) >= maxCacheEntries {
+			c.cache = make(map[cacheKey]*cacheEntry)
+		}
+	}
+	c.cache[key] = entry
+}
+
+func stringValue(v any) *string {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,137 @@
+// Package revocation implements the Redis schema that every service verifying
+// user-service access tokens consults to find out whether a token that is
+// still cryptographically valid has been revoked.
+//
+// The keys are:
+//
+//	revoked_session:<sid>  present while access tokens of session sid may still be in use
+//	not_before:<uid>       unix time in milliseconds; tokens of user uid issued before it are revoked
+//	suspended:<uid>        RFC 3339 end of the suspension, or empty when permanent
+package revocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+func SessionKey(sessionID int64) string {
+	return fmt.Sprintf("revoked_session:%d", sessionID)
+}
+
+func NotBeforeKey(userID int64) string {
+	return fmt.Sprintf("not_before:%d", userID)
+}
+
+func SuspendedKey(userID int64) string {
+	return fmt.Sprintf("suspended:%d", userID)
+}
+
+// Store writes revocations. Session and user revocations only need to outlive
+// the tokens they affect, so they expire after tokenTTL, which must cover the
+// longest lived token of a session, its refresh token included. When a local
+// Checker is given, its cache for the affected user is dropped on every write.
+type Store struct {
+	client   redis.UniversalClient
+	tokenTTL time.Duration
+	local    *Checker
+}
+
+func NewStore(client redis.UniversalClient, tokenTTL time.Duration, local *Checker) *Store {
+	return &Store{client: client, tokenTTL: tokenTTL, local: local}
+}
+
+// RevokeSessions rejects the access tokens of the given sessions of the user.
+func (s *Store) RevokeSessions(ctx context.Context, userID int64, sessionIDs ...int64) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	defer s.forget(userID)
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range sessionIDs {
+			pipe.Set(ctx, SessionKey(id), "1", s.tokenTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+// RevokeUser rejects every access token of the user issued before at.
+func (s *Store) RevokeUser(ctx context.Context, userID int64, at time.Time) error {
+	defer s.forget(userID)
+	return s.client.Set(ctx, NotBeforeKey(userID), strconv.FormatInt(at.UnixMilli(), 10), s.tokenTTL).Err()
+}
+
+// Suspend rejects every access token of the user until endsAt, or until
+// Unsuspend is called when endsAt is nil.
+func (s *Store) Suspend(ctx context.Context, userID int64, endsAt *time.Time) error {
+	defer s.forget(userID)
+
+	if endsAt == nil {
+		return s.client.Set(ctx, SuspendedKey(userID), "", 0).Err()
+	}
+
+	ttl := time.Until(*endsAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(ctx, SuspendedKey(userID), endsAt.UTC().Format(time.RFC3339), ttl).Err()
+}
+
+func (s *Store) Unsuspend(ctx context.Context, userID int64) error {
+	defer s.forget(userID)
+	return s.client.Del(ctx, SuspendedKey(userID)).Err()
+}
+
+func (s *Store) forget(userID int64) {
+	if s.local != nil {
+		s.local.Forget(userID)
+	}
+}
+
+// Status is the revocation state of one access token.
+type Status struct {
+	// Revoked is set when the session was revoked or the token predates the
+	// user's not-before time.
+	Revoked        bool
+	Suspended      bool
+	SuspendedUntil *time.Time
+}
+
+// Valid reports whether the token may be used.
+func (s *Status) Valid() bool {
+	return !s.Revoked && !s.Suspended
+}
+
+// ErrUnavailable wraps Redis failures so that callers can decide whether to
+// fail open or closed.
+var ErrUnavailable = errors.New("revocation store unavailable")
+
+func parseStatus(sessionRevoked bool, notBefore, suspended *string, issuedAt time.Time) *Status {
+	status := &Status{Revoked: sessionRevoked}
+
+	if notBefore != nil {
+		if ms, err := strconv.ParseInt(*notBefore, 10, 64); err == nil && issuedAt.UnixMilli() < ms {
+			status.Revoked = true
+		}
+	}
+
+	if suspended != nil {
+		status.Suspended = true
+		if *suspended != "" {
+			if endsAt, err := time.Parse(time.RFC3339, *suspended); err == nil {
+				if !endsAt.After(time.Now()) {
+					status.Suspended = false
+				} else {
+					status.SuspendedUntil = &endsAt
+				}
+			}
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,204 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestCheck_RevokedSession(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+	checker := NewChecker(client, 0)
+	store := NewStore(client, 15*time.Minute, checker)
+	issuedAt := time.Now()
+
+	status, err := checker.Check(ctx, 1, 10, issuedAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Valid() {
+		t.Fatalf("expected a fresh token to be valid, got %+v", status)
+	}
+
+	if err := store.RevokeSessions(ctx, 1, 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err = checker.Check(ctx, 1, 10, issuedAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Revoked {
+		t.Error("expected the revoked session to be rejected")
+	}
+
+	status, err = checker.Check(ctx, 1, 11, issuedAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Valid() {
+		t.Error("expected other sessions of the user to stay valid")
+	}
+}
+
+func TestCheck_NotBefore(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+	checker := NewChecker(client, 0)
+	store := NewStore(client, 15*time.Minute, checker)
+
+	revokedAt := time.Now()
+	if err := store.RevokeUser(ctx, 2, revokedAt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err := checker.Check(ctx, 2, 20, revokedAt.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Revoked {
+		t.Error("expected a token issued before not_before to be revoked")
+	}
+
+	status, err = checker.Check(ctx, 2, 0, revokedAt.Add(time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Valid() {
+		t.Error("expected a token issued after not_before to be valid")
+	}
+	status, err = checker.Check(ctx, 2, 20, revokedAt.Add(-100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Revoked {
+		t.Error("expected a token issued within the same second before not_before to be revoked")
+	}
+
+	status, err = checker.Check(ctx, 2, 0, revokedAt.Add(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Valid() {
+		t.Error("expected a token issued within the same second after not_before to be valid")
+	}
+}
+
+func TestCheck_Suspended(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+	checker := NewChecker(client, 0)
+	store := NewStore(client, 15*time.Minute, checker)
+
+	endsAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := store.Suspend(ctx, 3, &endsAt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err := checker.Check(ctx, 3, 30, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Suspended || status.SuspendedUntil == nil || !status.SuspendedUntil.Equal(endsAt) {
+		t.Fatalf("expected suspension until %v, got %+v", endsAt, status)
+	}
+
+	if err := store.Unsuspend(ctx, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Suspend(ctx, 4, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err = checker.Check(ctx, 3, 30, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Valid() {
+		t.Error("expected the lifted suspension to be gone")
+	}
+
+	status, err = checker.Check(ctx, 4, 40, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Suspended || status.SuspendedUntil != nil {
+		t.Errorf("expected a permanent suspension, got %+v", status)
+	}
+}
+
+func TestCheck_Cache(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+	checker := NewChecker(client, time.Minute)
+	issuedAt := time.Now()
+
+	// A store in another process does not know about this checker's cache.
+	remote := NewStore(client, 15*time.Minute, nil)
+	local := NewStore(client, 15*time.Minute, checker)
+
+	if _, err := checker.Check(ctx, 5, 50, issuedAt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := remote.RevokeSessions(ctx, 5, 50); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err := checker.Check(ctx, 5, 50, issuedAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Valid() {
+		t.Error("expected the cached answer until the cache entry expires")
+	}
+
+	if err := local.RevokeSessions(ctx, 5, 51); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, err = checker.Check(ctx, 5, 50, issuedAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Revoked {
+		t.Error("expected a local revocation to drop the cached answer")
+	}
+}
+
+func TestCheck_Unavailable(t *testing.T) {
+	mr, client := newTestClient(t)
+	mr.Close()
+
+	_, err := NewChecker(client, 0).Check(context.Background(), 6, 60, time.Now())
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestRevokeSessions_ExpireWithTokens(t *testing.T) {
+	mr, client := newTestClient(t)
+	store := NewStore(client, 15*time.Minute, nil)
+
+	if err := store.RevokeSessions(context.Background(), 7, 70, 71); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, key := range []string{SessionKey(70), SessionKey(71)} {
+		if ttl := mr.TTL(key); ttl != 15*time.Minute {
+			t.Errorf("expected %s to expire in 15m, got %v", key, ttl)
+		}
+	}
+}